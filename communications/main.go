@@ -11,6 +11,7 @@ import (
 
 	"satellite-coms/communications/model"
 	"satellite-coms/pkg/discovery/consul"
+	"satellite-coms/pkg/events"
 	discovery "satellite-coms/pkg/registry"
 
 	"github.com/redis/go-redis/v9"
@@ -21,6 +22,7 @@ const serviceName = "communications"
 var (
 	ctx          = context.Background()
 	redisClient  *redis.Client
+	stepConsumer *events.Consumer
 	logicalNodes []*model.LogicalNode
 	restrictions = make(map[string]struct{})
 )
@@ -28,7 +30,9 @@ var (
 func main() {
 	// Allow port to be specified
 	var port int
+	var consumerName string
 	flag.IntVar(&port, "port", 8083, "Communications service port")
+	flag.StringVar(&consumerName, "consumer", serviceName+"-1", "Stable consumer name in the step stream group")
 	flag.Parse()
 
 	log.Printf("🚀 Starting Communications service on port %d", port)
@@ -65,6 +69,11 @@ func main() {
 	// 4. Prepare initial state
 	logicalNodes = model.GetLogicalNodes()
 
+	stepConsumer, err = events.NewConsumer(ctx, redisClient, serviceName, consumerName)
+	if err != nil {
+		log.Fatalf("❌ Failed to join the step stream: %v", err)
+	}
+
 	// 5. Start HTTP server (health + send endpoint)
 	go startHTTPServer(port)
 
@@ -79,6 +88,7 @@ func startHTTPServer(port int) {
 	})
 
 	http.HandleFunc("/send", handleSendMessage)
+	http.HandleFunc("/lag", handleLag)
 
 	log.Printf("🌐 HTTP server listening on port %d", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
@@ -113,19 +123,31 @@ func handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Message sent"))
 }
 
+// handleLag reports how far this service is behind the simulator's step stream.
+func handleLag(w http.ResponseWriter, r *http.Request) {
+	lag, err := stepConsumer.Lag(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lag)
+}
+
 func runRedisLoop() {
-	// Subscribe to simulation.step
-	sub := redisClient.Subscribe(ctx, "simulation.step")
-	defer sub.Close()
-	log.Println("📡 Subscribed to simulation.step")
+	log.Printf("📡 Consuming %s as %s", events.StepStream, serviceName)
 
-	for msg := range sub.Channel() {
+	err := stepConsumer.Run(ctx, func(event events.StepEvent) error {
 		fmt.Print("\033[2J\033[H")
-		log.Println("🛰️ Received:", msg.Payload)
+		log.Printf("🛰️ Step %d (t=%.1fs): +%d/-%d links", event.Step, event.SimTimeS, len(event.Added), len(event.Removed))
 
 		model.CommunicationProtocol(logicalNodes, restrictions)
 
 		// Example hardcoded message (can be removed if using /send externally)
 		//model.SendMessage("srv_6c3a7", "srv_70f8b", "Hello", logicalNodes, restrictions)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("❌ Step stream consumer stopped: %v", err)
 	}
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"satellite-coms/simulator/metrics"
+	"satellite-coms/simulator/simulation"
+)
+
+// params is one point of the parameter sweep. Zero or negative values keep
+// whatever the scenario defines.
+type params struct {
+	Satellites  int
+	Ports       int
+	PortGen     int
+	FailureRate float64
+}
+
+type job struct {
+	index int
+	p     params
+	seed  int64
+}
+
+type result struct {
+	index int
+	run   metrics.RunMetrics
+}
+
+// experiment runs a scenario over a seed range and a parameter sweep, in
+// parallel, and prints one summary row per parameter combination.
+func main() {
+	var (
+		scenarioPath string
+		seeds        string
+		steps        int
+		demand       float64
+		workers      int
+		satellites   string
+		ports        string
+		portgen      string
+		failure      string
+	)
+	flag.StringVar(&scenarioPath, "scenario", "", "Scenario JSON file (defaults to the built-in constellation)")
+	flag.StringVar(&seeds, "seeds", "1-30", "Seed range, e.g. 1-100")
+	flag.IntVar(&steps, "steps", 2000, "Simulation steps per run")
+	flag.Float64Var(&demand, "demand", 10, "Traffic units each ground pair offers per step")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Parallel workers")
+	flag.StringVar(&satellites, "satellites", "", "Satellites per shell to sweep, e.g. 3,6,9")
+	flag.StringVar(&ports, "ports", "", "Satellite port counts to sweep, e.g. 1,2,3")
+	flag.StringVar(&portgen, "portgen", "", "Satellite port generations to sweep, e.g. 3,6")
+	flag.StringVar(&failure, "failure", "0", "Satellite failure rates to sweep, e.g. 0,0.05,0.1")
+	flag.Parse()
+
+	scenario := simulation.DefaultScenario()
+	if scenarioPath != "" {
+		var err error
+		if scenario, err = simulation.LoadScenario(scenarioPath); err != nil {
+			log.Fatalf("❌ Failed to load scenario: %v", err)
+		}
+	}
+
+	if !(demand > 0) || math.IsInf(demand, 1) {
+		log.Fatalf("❌ Invalid -demand: %g must be a positive number", demand)
+	}
+	first, last, err := parseRange(seeds)
+	if err != nil {
+		log.Fatalf("❌ Invalid -seeds: %v", err)
+	}
+	satList, err := parseInts(satellites)
+	if err != nil {
+		log.Fatalf("❌ Invalid -satellites: %v", err)
+	}
+	portList, err := parseInts(ports)
+	if err != nil {
+		log.Fatalf("❌ Invalid -ports: %v", err)
+	}
+	genList, err := parseInts(portgen)
+	if err != nil {
+		log.Fatalf("❌ Invalid -portgen: %v", err)
+	}
+	failList, err := parseFloats(failure)
+	if err != nil {
+		log.Fatalf("❌ Invalid -failure: %v", err)
+	}
+
+	var sweep []params
+	for _, s := range satList {
+		for _, p := range portList {
+			for _, g := range genList {
+				for _, f := range failList {
+					point := params{Satellites: s, Ports: p, PortGen: g, FailureRate: f}
+					if _, err := apply(scenario, point); err != nil {
+						log.Fatalf("❌ Invalid sweep point %+v: %v", point, err)
+					}
+					sweep = append(sweep, point)
+				}
+			}
+		}
+	}
+
+	jobs := make(chan job)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				// Every sweep point was validated above
+				s, _ := apply(scenario, j.p)
+				results <- result{index: j.index, run: runOnce(s, j.seed, j.p.FailureRate, steps, demand)}
+			}
+		}()
+	}
+
+	go func() {
+		for i, p := range sweep {
+			for seed := first; seed <= last; seed++ {
+				jobs <- job{index: i, p: p, seed: seed}
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	runs := make([][]metrics.RunMetrics, len(sweep))
+	for r := range results {
+		runs[r.index] = append(runs[r.index], r.run)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "satellites\tports\tportgen\tfailure\truns\tdelivery_ratio\tground_reachability\tavg_hops\tlinks\tcomponents")
+	for i, p := range sweep {
+		var delivery, reachability, hops, links, components []float64
+		for _, r := range runs[i] {
+			delivery = append(delivery, r.DeliveryRatio)
+			reachability = append(reachability, r.GroundReachability)
+			hops = append(hops, r.AvgPathHops)
+			links = append(links, r.Links)
+			components = append(components, r.Components)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			label(p.Satellites), label(p.Ports), label(p.PortGen), p.FailureRate, len(runs[i]),
+			format(metrics.Summarize(delivery)), format(metrics.Summarize(reachability)), format(metrics.Summarize(hops)),
+			format(metrics.Summarize(links)), format(metrics.Summarize(components)))
+	}
+	tw.Flush()
+}
+
+// runOnce simulates one seeded scenario and returns its step-averaged metrics,
+// with the share of the offered ground traffic delivered over the run.
+func runOnce(scenario simulation.Scenario, seed int64, failureRate float64, steps int, demand float64) metrics.RunMetrics {
+	planet, nodes := scenario.Build(seed, failureRate)
+	links := simulation.NewLinkLayer()
+	links.Units = scenario.Scale(planet)
+	links.Spectrum = scenario.Spectrum
+	links.Hysteresis = scenario.Hysteresis
+	links.Update(nodes)
+	history := make([]metrics.StepMetrics, 0, steps)
+	var offered, delivered float64
+	for step := 0; step < steps; step++ {
+		matrix := links.Matrix()
+		history = append(history, metrics.Compute(step, nodes, matrix))
+		o, d := metrics.Delivery(nodes, matrix, demand)
+		offered += o
+		delivered += d
+		simulation.Step(nodes)
+		links.Update(nodes)
+	}
+	run := metrics.Average(history)
+	if offered > 0 {
+		run.DeliveryRatio = delivered / offered
+	}
+	return run
+}
+
+// apply returns a copy of the scenario with the sweep parameters applied to
+// every shell, validated again since the port count constrains the per-port
+// antennas and channels.
+func apply(s simulation.Scenario, p params) (simulation.Scenario, error) {
+	shells := make([]simulation.ShellSpec, len(s.Shells))
+	copy(shells, s.Shells)
+	for i := range shells {
+		if p.Satellites > 0 {
+			shells[i].Satellites = p.Satellites
+		}
+		if p.Ports > 0 {
+			shells[i].Ports = p.Ports
+		}
+		if p.PortGen > 0 {
+			shells[i].PortGen = p.PortGen
+		}
+	}
+	s.Shells = shells
+	return s, s.Validate()
+}
+
+func format(s metrics.Summary) string {
+	return fmt.Sprintf("%.3f ± %.3f", s.Mean, s.CI95)
+}
+
+func label(v int) string {
+	if v <= 0 {
+		return "scenario"
+	}
+	return strconv.Itoa(v)
+}
+
+func parseRange(s string) (int64, int64, error) {
+	from, to, found := strings.Cut(s, "-")
+	first, err := strconv.ParseInt(strings.TrimSpace(from), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return first, first, nil
+	}
+	last, err := strconv.ParseInt(strings.TrimSpace(to), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if last < first {
+		return 0, 0, fmt.Errorf("range end %d is before start %d", last, first)
+	}
+	return first, last, nil
+}
+
+// parseInts parses a comma-separated list; an empty list sweeps only the scenario value.
+func parseInts(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return []int{0}, nil
+	}
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func parseFloats(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return []float64{0}, nil
+	}
+	var out []float64
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		if v < 0 || v > 1 {
+			return nil, fmt.Errorf("failure rate %v must be within [0, 1]", v)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
@@ -3,12 +3,17 @@ package httpclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"satellite-coms/pkg/visibility"
+
 	"github.com/hashicorp/consul/api"
 	"github.com/redis/go-redis/v9"
 )
@@ -34,18 +39,47 @@ func InitRedis(addr string) {
 
 // --- Simulator helpers ---
 
-var simulatorBaseURL string
-var simulatorOnce sync.Once
+// simulatorURL is the simulator's address once discovered. Only successful
+// lookups are kept, and a request that cannot reach the address clears it,
+// so a simulator that was not registered yet or moved is looked up again.
+var (
+	simulatorMu  sync.Mutex
+	simulatorURL string
+)
 
 func getSimulatorBaseURL() (string, error) {
-	var err error
-	simulatorOnce.Do(func() {
-		simulatorBaseURL, err = getServiceURLFromConsulInternal("simulator", "simulator_url")
-	})
-	if err != nil {
-		return "", err
+	simulatorMu.Lock()
+	defer simulatorMu.Unlock()
+	if simulatorURL == "" {
+		url, err := getServiceURLFromConsulInternal("simulator", "simulator_url")
+		if err != nil {
+			return "", err
+		}
+		simulatorURL = url
+	}
+	return simulatorURL, nil
+}
+
+// forgetSimulator drops the cached address after a request could not reach it.
+func forgetSimulator(err error) {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return
 	}
-	return simulatorBaseURL, nil
+	simulatorMu.Lock()
+	simulatorURL = ""
+	simulatorMu.Unlock()
+}
+
+// StatusError is a non-200 answer of another service, with the message of
+// its body.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
 }
 
 func fetchJSON(url string) (interface{}, error) {
@@ -55,6 +89,11 @@ func fetchJSON(url string) (interface{}, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
 	var parsed interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
 		return nil, err
@@ -62,42 +101,64 @@ func fetchJSON(url string) (interface{}, error) {
 	return parsed, nil
 }
 
-// FetchVisibility returns the visibility matrix from the simulator
-func FetchVisibility() []interface{} {
+// FetchSnapshot returns nodes, visibility and links of a single simulator
+// step, with visibility as an adjacency list keyed by node ID. With since >= 0
+// it returns nil when the simulator is still at that step.
+func FetchSnapshot(since int) (map[string]interface{}, error) {
 	baseURL, err := getSimulatorBaseURL()
 	if err != nil {
-		log.Fatalf("❌ Failed to discover simulator service: %v", err)
+		return nil, fmt.Errorf("failed to discover simulator service: %w", err)
 	}
 
-	data, err := fetchJSON(fmt.Sprintf("%s/visibility", baseURL))
+	url := fmt.Sprintf("%s/snapshot", baseURL)
+	if since >= 0 {
+		url = fmt.Sprintf("%s?since=%d", url, since)
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatalf("❌ Failed to fetch visibility: %v", err)
+		return nil, err
 	}
+	req.Header.Set("Accept", visibility.MediaAdjacency)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		forgetSimulator(err)
+		return nil, fmt.Errorf("failed to fetch snapshot: %w", err)
+	}
+	defer resp.Body.Close()
 
-	matrix, ok := data.([]interface{})
-	if !ok {
-		log.Fatal("❌ Failed to cast visibility to []interface{}")
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch snapshot: %s", resp.Status)
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
 	}
-	return matrix
+	return snapshot, nil
 }
 
-// FetchNodes returns all simulator nodes
-func FetchNodes() []interface{} {
+// FetchContacts returns the simulator's contact plan over the next horizonS
+// sim seconds. A horizon the simulator refuses comes back as a *StatusError.
+func FetchContacts(horizonS float64) (map[string]interface{}, error) {
 	baseURL, err := getSimulatorBaseURL()
 	if err != nil {
-		log.Fatalf("❌ Failed to discover simulator service: %v", err)
+		return nil, fmt.Errorf("failed to discover simulator service: %w", err)
 	}
 
-	data, err := fetchJSON(fmt.Sprintf("%s/positions", baseURL))
+	data, err := fetchJSON(fmt.Sprintf("%s/contacts?horizon_s=%g", baseURL, horizonS))
 	if err != nil {
-		log.Fatalf("❌ Failed to fetch positions: %v", err)
+		forgetSimulator(err)
+		return nil, fmt.Errorf("failed to fetch contacts: %w", err)
 	}
 
-	items, ok := data.([]interface{})
+	plan, ok := data.(map[string]interface{})
 	if !ok {
-		log.Fatal("❌ Expected JSON array at top level")
+		return nil, errors.New("expected JSON object for the contact plan")
 	}
-	return items
+	return plan, nil
 }
 
 // --- Generic service discovery ---
@@ -3,31 +3,52 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
+	"satellite-coms/pathfinder/internal/httpclient"
 	"satellite-coms/pathfinder/model"
 	"satellite-coms/pkg/discovery/consul"
+	"satellite-coms/pkg/events"
 	discovery "satellite-coms/pkg/registry"
 )
 
 const serviceName = "pathfinder"
 
+// maxPaths caps k on /paths
+const maxPaths = 32
+
+// maxBatch caps the number of queries in one /paths/batch request
+const maxBatch = 1000
+
 var (
-	ctx         = context.Background()
-	redisClient *redis.Client
+	ctx          = context.Background()
+	redisClient  *redis.Client
+	stepConsumer *events.Consumer
+	graphs       = model.NewGraphCache()
 )
 
 func main() {
 	var port int
+	var consumerName string
 	flag.IntVar(&port, "port", 8082, "Pathfinder service port")
+	flag.StringVar(&consumerName, "consumer", serviceName+"-1", "Stable consumer name in the step stream group")
+	flag.Float64Var(&graphs.LifetimeHorizonS, "lifetime-horizon", graphs.LifetimeHorizonS, "Sim seconds of contact plan used to predict route lifetimes")
 	flag.Parse()
+	if h := graphs.LifetimeHorizonS; !(h > 0) || math.IsInf(h, 1) {
+		log.Fatalf("❌ -lifetime-horizon must be a positive number of sim seconds, got %g", h)
+	}
 
 	log.Printf("🚀 Starting Pathfinder service on port %d", port)
 
@@ -63,8 +84,13 @@ func main() {
 		}
 	}()
 
-	// 4️⃣ Subscribe to Redis events
-	go subscribeToRedisEvents()
+	// 4️⃣ Consume simulation steps
+	stepConsumer, err = events.NewConsumer(ctx, redisClient, serviceName, consumerName)
+	if err != nil {
+		log.Fatalf("❌ Failed to join the step stream: %v", err)
+	}
+	go graphs.Run(ctx)
+	go consumeSteps()
 
 	// 5️⃣ HTTP routes
 	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
@@ -72,50 +98,418 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
+	http.HandleFunc("/lag", func(w http.ResponseWriter, r *http.Request) {
+		lag, err := stepConsumer.Lag(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(lag)
+	})
+
 	http.HandleFunc("/path", func(w http.ResponseWriter, r *http.Request) {
 		start := r.URL.Query().Get("start")
 		end := r.URL.Query().Get("end")
 		restrictedStr := r.URL.Query().Get("restricted")
+		strategyName := r.URL.Query().Get("strategy")
+		if strategyName == "" {
+			// mode= predates strategies
+			switch mode := r.URL.Query().Get("mode"); mode {
+			case "", "widest":
+			case "latency":
+				strategyName = "min-latency"
+			default:
+				http.Error(w, fmt.Sprintf("unknown mode %q (available: widest, latency)", mode), http.StatusBadRequest)
+				return
+			}
+		}
+		strategy, ok := model.LookupStrategy(strategyName)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown strategy %q (available: %s)", strategyName, strings.Join(model.StrategyNames(), ", ")), http.StatusBadRequest)
+			return
+		}
 
 		if start == "" || end == "" {
 			http.Error(w, "start and end parameters are required", http.StatusBadRequest)
 			return
 		}
 
-		restricted := make(map[string]bool)
-		if restrictedStr != "" {
-			for _, node := range strings.Split(restrictedStr, ",") {
-				restricted[strings.TrimSpace(node)] = true
-			}
-		}
+		restricted := parseRestricted(restrictedStr)
 
-		g := model.CreateGraph() // Uses Redis + Consul internally
-		path, found := g.WidestPath(start, end, restricted)
+		g, err := graphs.Graph() // Rebuilt after step events
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		path, found := strategy.Path(g, start, end, restricted)
 
 		w.Header().Set("Content-Type", "application/json")
 		if found {
-			_ = json.NewEncoder(w).Encode(map[string]interface{}{"path": path})
+			report := g.Report(path)
+			resp := map[string]interface{}{
+				"path":           path,
+				"step":           g.Step(),
+				"strategy":       strategy.Name(),
+				"hops":           report.Hops,
+				"total_hops":     report.TotalHops,
+				"bottleneck_hop": report.BottleneckHop,
+				"delay_ms":       report.DelayMs,
+			}
+			if lifetime := pathLifetime(g, contactPlan(g), path); lifetime != nil {
+				resp["lifetime"] = lifetime
+			}
+			if r.URL.Query().Get("debug") == "true" {
+				resp["debug"] = g.Explain(strategy, start, end, restricted, path)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
 		} else {
-			w.WriteHeader(http.StatusNotFound)
-			_ = json.NewEncoder(w).Encode(map[string]string{
+			resp := map[string]interface{}{
 				"error": fmt.Sprintf("No path found from %s to %s", start, end),
-			})
+				"step":  g.Step(),
+			}
+			if r.URL.Query().Get("debug") == "true" {
+				resp["debug"] = g.Explain(strategy, start, end, restricted, nil)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(resp)
 		}
 	})
 
+	http.HandleFunc("/paths", pathsHandler)
+	http.HandleFunc("/paths/batch", batchPathsHandler)
+	http.HandleFunc("/cgr", contactRoutesHandler)
+	http.HandleFunc("/topology/analysis", analysisHandler)
+	http.HandleFunc("/graph", graphHandler)
+
 	// 6️⃣ Start HTTP server
 	log.Printf("🌐 Pathfinder HTTP server listening on port %d", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", port), nil))
 }
 
-// Redis subscription
-func subscribeToRedisEvents() {
-	sub := redisClient.Subscribe(ctx, "simulation.step")
-	defer sub.Close()
-	log.Println("📡 Pathfinder subscribed to simulation.step")
+// pathsHandler returns up to k alternative paths, either loop-free (Yen) or
+// link- or port-disjoint (Suurballe), ranked by latency or hops.
+func pathsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := q.Get("start"), q.Get("end")
+	if start == "" || end == "" {
+		http.Error(w, "start and end parameters are required", http.StatusBadRequest)
+		return
+	}
 
-	// Disabled to avoid spamming logs
-	// for msg := range sub.Channel() {
-	//     log.Println("🛰️ Step received:", msg.Payload)
-	// }
+	k := 3
+	if s := q.Get("k"); s != "" {
+		var err error
+		if k, err = strconv.Atoi(s); err != nil || k < 1 || k > maxPaths {
+			http.Error(w, fmt.Sprintf("k must be between 1 and %d", maxPaths), http.StatusBadRequest)
+			return
+		}
+	}
+	weight := q.Get("weight")
+	if weight != "" && weight != model.WeightLatency && weight != model.WeightHops {
+		http.Error(w, "weight must be latency or hops", http.StatusBadRequest)
+		return
+	}
+	disjoint := q.Get("disjoint")
+	restricted := parseRestricted(q.Get("restricted"))
+
+	g, err := graphs.Graph()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var paths []model.RankedPath
+	switch disjoint {
+	case "":
+		paths = g.KShortestPaths(start, end, k, restricted, weight)
+	case model.DisjointLink, model.DisjointNode:
+		paths = g.DisjointPaths(start, end, k, disjoint == model.DisjointNode, restricted, weight)
+	default:
+		http.Error(w, "disjoint must be link or node", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(paths) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": fmt.Sprintf("No path found from %s to %s", start, end),
+			"step":  g.Step(),
+		})
+		return
+	}
+	plan := contactPlan(g)
+	for i := range paths {
+		paths[i].Lifetime = pathLifetime(g, plan, paths[i].Path)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"paths": paths, "step": g.Step()})
+}
+
+// batchQuery is one route request of /paths/batch.
+type batchQuery struct {
+	Start      string   `json:"start"`
+	End        string   `json:"end"`
+	Restricted []string `json:"restricted"`
+	Strategy   string   `json:"strategy"`
+}
+
+// batchResult answers one batchQuery; Error is set when it has no path.
+type batchResult struct {
+	Path          []string        `json:"path,omitempty"`
+	Strategy      string          `json:"strategy,omitempty"`
+	TotalHops     int             `json:"total_hops,omitempty"`
+	BottleneckHop int             `json:"bottleneck_hop,omitempty"`
+	DelayMs       float64         `json:"delay_ms,omitempty"`
+	Lifetime      *model.Lifetime `json:"lifetime,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// batchPathsHandler answers a JSON list of queries against one graph, so every
+// answer is for the same step. Queries are independent and run concurrently;
+// results keep the order of the queries.
+func batchPathsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON list of queries", http.StatusMethodNotAllowed)
+		return
+	}
+	var queries []batchQuery
+	if err := json.NewDecoder(r.Body).Decode(&queries); err != nil {
+		http.Error(w, fmt.Sprintf("invalid queries: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(queries) > maxBatch {
+		http.Error(w, fmt.Sprintf("at most %d queries per batch", maxBatch), http.StatusBadRequest)
+		return
+	}
+
+	g, err := graphs.Graph()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	plan := contactPlan(g)
+	results := make([]batchResult, len(queries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.GOMAXPROCS(0), len(queries)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = answer(g, plan, queries[j])
+			}
+		}()
+	}
+	for j := range queries {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "step": g.Step()})
+}
+
+func answer(g *model.Graph, plan *model.ContactPlan, q batchQuery) batchResult {
+	if q.Start == "" || q.End == "" {
+		return batchResult{Error: "start and end are required"}
+	}
+	strategy, ok := model.LookupStrategy(q.Strategy)
+	if !ok {
+		return batchResult{Error: fmt.Sprintf("unknown strategy %q", q.Strategy)}
+	}
+	restricted := make(map[string]bool, len(q.Restricted))
+	for _, node := range q.Restricted {
+		restricted[strings.TrimSpace(node)] = true
+	}
+
+	path, found := strategy.Path(g, q.Start, q.End, restricted)
+	if !found {
+		return batchResult{Strategy: strategy.Name(), Error: fmt.Sprintf("No path found from %s to %s", q.Start, q.End)}
+	}
+	report := g.Report(path)
+	return batchResult{
+		Path:          path,
+		Strategy:      strategy.Name(),
+		TotalHops:     report.TotalHops,
+		BottleneckHop: report.BottleneckHop,
+		DelayMs:       report.DelayMs,
+		Lifetime:      pathLifetime(g, plan, path),
+	}
+}
+
+// contactPlan returns the contact plan for route lifetimes, or nil when it
+// cannot be fetched or another request is fetching it; lifetimes are then
+// left out rather than failing or holding up routes.
+func contactPlan(g *model.Graph) *model.ContactPlan {
+	plan, err := graphs.ContactPlan(g)
+	if err != nil {
+		log.Printf("⚠️ No contact plan for route lifetimes: %v", err)
+	}
+	return plan
+}
+
+// pathLifetime predicts the lifetime of a path, or nil without a plan.
+func pathLifetime(g *model.Graph, plan *model.ContactPlan, path []string) *model.Lifetime {
+	if plan == nil {
+		return nil
+	}
+	lifetime := plan.Lifetime(g, path)
+	return &lifetime
+}
+
+// analysisHandler reports structural metrics of the current graph at node
+// level, port level or both.
+func analysisHandler(w http.ResponseWriter, r *http.Request) {
+	var levels []string
+	switch level := r.URL.Query().Get("level"); level {
+	case "", "both":
+		levels = []string{model.LevelNode, model.LevelPort}
+	case model.LevelNode, model.LevelPort:
+		levels = []string{level}
+	default:
+		http.Error(w, "level must be node, port or both", http.StatusBadRequest)
+		return
+	}
+
+	g, err := graphs.Graph()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	resp := map[string]interface{}{"step": g.Step()}
+	for _, level := range levels {
+		resp[level] = g.Analyze(level)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// graphHandler exports the current graph as DOT, GraphML or node-link JSON,
+// at port level (default) or node level.
+func graphHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = model.FormatJSON
+	}
+	contentType, ok := model.ExportContentTypes[format]
+	if !ok {
+		http.Error(w, "format must be dot, graphml or json", http.StatusBadRequest)
+		return
+	}
+	level := r.URL.Query().Get("level")
+	if level == "" {
+		level = model.LevelPort
+	}
+	if level != model.LevelPort && level != model.LevelNode {
+		http.Error(w, "level must be port or node", http.StatusBadRequest)
+		return
+	}
+
+	g, err := graphs.Graph()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Sim-Step", strconv.Itoa(g.Step()))
+	if err := g.Export(w, format, level); err != nil {
+		log.Println("❌ Failed to export graph:", err)
+	}
+}
+
+// contactRoutesHandler returns store-carry-forward routes between two nodes
+// over the predicted contact plan, for messages whose ends are never connected
+// at the same time. Port IDs are accepted and mapped to their nodes.
+func contactRoutesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := nodeID(q.Get("start")), nodeID(q.Get("end"))
+	if start == "" || end == "" {
+		http.Error(w, "start and end parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	horizon := 3600.0
+	if s := q.Get("horizon_s"); s != "" {
+		var err error
+		if horizon, err = strconv.ParseFloat(s, 64); err != nil || !(horizon >= 0) || math.IsInf(horizon, 1) {
+			http.Error(w, "horizon_s must be a finite non-negative number", http.StatusBadRequest)
+			return
+		}
+	}
+	k := 3
+	if s := q.Get("k"); s != "" {
+		var err error
+		if k, err = strconv.Atoi(s); err != nil || k < 1 || k > maxPaths {
+			http.Error(w, fmt.Sprintf("k must be between 1 and %d", maxPaths), http.StatusBadRequest)
+			return
+		}
+	}
+	restricted := make(map[string]bool)
+	for node := range parseRestricted(q.Get("restricted")) {
+		restricted[nodeID(node)] = true
+	}
+
+	plan, err := model.CreateContactPlan(horizon)
+	var status *httpclient.StatusError
+	if errors.As(err, &status) && status.Code == http.StatusBadRequest {
+		// The simulator bounds the horizon by the samples it takes
+		http.Error(w, status.Message, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	routes := plan.Routes(start, end, k, restricted)
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(routes) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": fmt.Sprintf("No contact route from %s to %s within %gs", start, end, horizon),
+			"step":  plan.Step,
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"routes":     routes,
+		"step":       plan.Step,
+		"sim_time_s": plan.SimTimeS,
+	})
+}
+
+// nodeID strips the port from a port ID.
+func nodeID(v string) string {
+	if i := strings.Index(v, ":port"); i >= 0 {
+		return v[:i]
+	}
+	return v
+}
+
+// parseRestricted reads a comma-separated list of vertices to avoid.
+func parseRestricted(s string) map[string]bool {
+	restricted := make(map[string]bool)
+	if s != "" {
+		for _, node := range strings.Split(s, ",") {
+			restricted[strings.TrimSpace(node)] = true
+		}
+	}
+	return restricted
+}
+
+// consumeSteps follows the simulator's step stream and marks the cached graph
+// stale; graphs.Run refreshes it in the background
+func consumeSteps() {
+	log.Printf("📡 Pathfinder consuming %s", events.StepStream)
+
+	err := stepConsumer.Run(ctx, func(event events.StepEvent) error {
+		// Disabled to avoid spamming logs
+		// log.Println("🛰️ Step received:", event.Step)
+		graphs.Invalidate()
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Step stream consumer stopped: %v", err)
+	}
 }
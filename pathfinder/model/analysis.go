@@ -0,0 +1,251 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Analysis levels: the port-level graph as routed on, or the node-level graph
+// where two nodes are adjacent when any of their ports are.
+const (
+	LevelNode = "node"
+	LevelPort = "port"
+)
+
+// Analysis holds structural metrics of the graph at one level. Distances are
+// in hops; the diameter is the longest shortest path within any component.
+// Isolated ground nodes cannot reach any other ground node.
+type Analysis struct {
+	Level               string      `json:"level"`
+	Vertices            int         `json:"vertices"`
+	Edges               int         `json:"edges"`
+	AverageDegree       float64     `json:"average_degree"`
+	Components          [][]string  `json:"components"`
+	ArticulationPoints  []string    `json:"articulation_points"`
+	Bridges             [][2]string `json:"bridges"`
+	Diameter            int         `json:"diameter"`
+	IsolatedGroundNodes []string    `json:"isolated_ground_nodes"`
+}
+
+// undirected is a graph indexed by position, for the analysis algorithms.
+type undirected struct {
+	names []string
+	adj   [][]int
+}
+
+// Analyze computes the metrics at the given level.
+func (g *Graph) Analyze(level string) Analysis {
+	u := g.undirected(level)
+	a := Analysis{
+		Level:               level,
+		Vertices:            len(u.names),
+		ArticulationPoints:  []string{},
+		Bridges:             [][2]string{},
+		IsolatedGroundNodes: []string{},
+	}
+
+	degrees := 0
+	for _, neighbors := range u.adj {
+		degrees += len(neighbors)
+	}
+	a.Edges = degrees / 2
+	if len(u.names) > 0 {
+		a.AverageDegree = float64(degrees) / float64(len(u.names))
+	}
+
+	component := u.components()
+	a.Components = make([][]string, 0)
+	byComponent := make(map[int][]string)
+	for v, c := range component {
+		byComponent[c] = append(byComponent[c], u.names[v])
+	}
+	for c := 0; c < len(byComponent); c++ {
+		a.Components = append(a.Components, byComponent[c])
+	}
+	sort.SliceStable(a.Components, func(x, y int) bool { return len(a.Components[x]) > len(a.Components[y]) })
+
+	cut, bridges := u.cuts()
+	for v := range u.names {
+		if cut[v] {
+			a.ArticulationPoints = append(a.ArticulationPoints, u.names[v])
+		}
+	}
+	for _, b := range bridges {
+		a.Bridges = append(a.Bridges, [2]string{u.names[b[0]], u.names[b[1]]})
+	}
+
+	for v := range u.names {
+		a.Diameter = max(a.Diameter, u.eccentricity(v))
+	}
+
+	// A ground node is isolated when no other ground node shares a component
+	// with any of its vertices
+	groundComponents := make(map[string]map[int]bool)
+	for v, name := range u.names {
+		node := g.node(name)
+		if isGround(node) {
+			if groundComponents[node] == nil {
+				groundComponents[node] = make(map[int]bool)
+			}
+			groundComponents[node][component[v]] = true
+		}
+	}
+	for node, comps := range groundComponents {
+		isolated := true
+		for other, otherComps := range groundComponents {
+			for c := range comps {
+				if other != node && otherComps[c] {
+					isolated = false
+				}
+			}
+		}
+		if isolated {
+			a.IsolatedGroundNodes = append(a.IsolatedGroundNodes, node)
+		}
+	}
+	sort.Strings(a.IsolatedGroundNodes)
+	return a
+}
+
+// isGround reports whether a node is a ground server, as named by the simulator.
+func isGround(node string) bool {
+	return strings.HasPrefix(node, "srv_")
+}
+
+// undirected returns the port-level graph, or the node-level graph built from
+// the links between nodes, with vertices sorted by name.
+func (g *Graph) undirected(level string) undirected {
+	neighbors := make(map[string][]string)
+	if level == LevelPort {
+		// Ports without links are not in adj
+		for v := range g.nodeOf {
+			neighbors[v] = nil
+		}
+		for v, adj := range g.adj {
+			neighbors[v] = adj
+		}
+	} else {
+		for node := range g.position {
+			neighbors[node] = nil
+		}
+		for key := range g.links {
+			neighbors[key[0]] = append(neighbors[key[0]], key[1])
+			neighbors[key[1]] = append(neighbors[key[1]], key[0])
+		}
+	}
+
+	u := undirected{}
+	for v := range neighbors {
+		u.names = append(u.names, v)
+	}
+	sort.Strings(u.names)
+	index := make(map[string]int, len(u.names))
+	for i, v := range u.names {
+		index[v] = i
+	}
+	u.adj = make([][]int, len(u.names))
+	for i, v := range u.names {
+		for _, w := range neighbors[v] {
+			if j, ok := index[w]; ok && j != i {
+				u.adj[i] = append(u.adj[i], j)
+			}
+		}
+		sort.Ints(u.adj[i])
+	}
+	return u
+}
+
+// components labels every vertex with its connected component, numbered in
+// order of their smallest vertex.
+func (u undirected) components() []int {
+	component := make([]int, len(u.names))
+	for i := range component {
+		component[i] = -1
+	}
+	next := 0
+	for s := range u.names {
+		if component[s] >= 0 {
+			continue
+		}
+		component[s] = next
+		stack := []int{s}
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, w := range u.adj[v] {
+				if component[w] < 0 {
+					component[w] = next
+					stack = append(stack, w)
+				}
+			}
+		}
+		next++
+	}
+	return component
+}
+
+// cuts finds the articulation points and bridges with Tarjan's low-link DFS.
+func (u undirected) cuts() ([]bool, [][2]int) {
+	n := len(u.names)
+	order, low := make([]int, n), make([]int, n)
+	cut := make([]bool, n)
+	var bridges [][2]int
+	counter := 0
+
+	var visit func(v, parent int)
+	visit = func(v, parent int) {
+		counter++
+		order[v], low[v] = counter, counter
+		children := 0
+		for _, w := range u.adj[v] {
+			if w == parent {
+				continue
+			}
+			if order[w] > 0 {
+				low[v] = min(low[v], order[w])
+				continue
+			}
+			children++
+			visit(w, v)
+			low[v] = min(low[v], low[w])
+			if parent >= 0 && low[w] >= order[v] {
+				cut[v] = true
+			}
+			if low[w] > order[v] {
+				bridges = append(bridges, [2]int{v, w})
+			}
+		}
+		if parent < 0 && children > 1 {
+			cut[v] = true
+		}
+	}
+	for v := 0; v < n; v++ {
+		if order[v] == 0 {
+			visit(v, -1)
+		}
+	}
+	return cut, bridges
+}
+
+// eccentricity returns the largest hop distance from v to a reachable vertex.
+func (u undirected) eccentricity(v int) int {
+	dist := make([]int, len(u.names))
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[v] = 0
+	farthest := 0
+	queue := []int{v}
+	for len(queue) > 0 {
+		x := queue[0]
+		queue = queue[1:]
+		for _, w := range u.adj[x] {
+			if dist[w] < 0 {
+				dist[w] = dist[x] + 1
+				farthest = max(farthest, dist[w])
+				queue = append(queue, w)
+			}
+		}
+	}
+	return farthest
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"satellite-coms/pathfinder/internal/httpclient"
+)
+
+// refreshRetry is how long the cache waits before retrying a failed refresh.
+const refreshRetry = time.Second
+
+// GraphCache holds the graph of the latest simulator step. Graphs are never
+// modified once built, so readers can keep using the one they got while a
+// newer step replaces it.
+type GraphCache struct {
+	mu    sync.RWMutex
+	graph *Graph
+	stale chan struct{}
+
+	// LifetimeHorizonS is how far ahead the contact plan used for route
+	// lifetimes is predicted
+	LifetimeHorizonS float64
+
+	planMu       sync.Mutex
+	plan         *ContactPlan
+	planFetching bool
+}
+
+func NewGraphCache() *GraphCache {
+	return &GraphCache{LifetimeHorizonS: 3600, stale: make(chan struct{}, 1)}
+}
+
+// Graph returns the cached graph, building it on first use.
+func (c *GraphCache) Graph() (*Graph, error) {
+	c.mu.RLock()
+	g := c.graph
+	c.mu.RUnlock()
+	if g != nil {
+		return g, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.graph == nil {
+		g, err := CreateGraph()
+		if err != nil {
+			return nil, err
+		}
+		c.graph = g
+	}
+	return c.graph, nil
+}
+
+// Invalidate marks the graph as stale after a step event. It never blocks:
+// events arriving while a refresh runs are coalesced into the next one, so a
+// simulator stepping faster than snapshots can be fetched costs one rebuild
+// per refresh rather than one per step.
+func (c *GraphCache) Invalidate() {
+	select {
+	case c.stale <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes the graph whenever it was invalidated, until ctx is done. A
+// failed refresh is retried, so an unreachable or restarting simulator only
+// leaves the previous graph in place.
+func (c *GraphCache) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stale:
+		}
+		for {
+			err := c.Refresh()
+			if err == nil {
+				break
+			}
+			log.Printf("⚠️ Failed to refresh the graph: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(refreshRetry):
+			}
+		}
+	}
+}
+
+// Refresh rebuilds the graph from the latest snapshot. The snapshot is
+// fetched conditionally, so a graph that is still current costs no rebuild.
+// Step numbers are not compared, since a restarted simulator counts from zero.
+func (c *GraphCache) Refresh() error {
+	c.mu.RLock()
+	current := c.graph
+	c.mu.RUnlock()
+
+	since := -1
+	if current != nil {
+		since = current.Step()
+	}
+	snapshot, err := httpclient.FetchSnapshot(since)
+	if err != nil || snapshot == nil {
+		return err
+	}
+	g, err := graphFromSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.graph = g
+	c.mu.Unlock()
+	return nil
+}
+
+// ContactPlan returns a contact plan covering the graph's sim time. A plan is
+// reused until half its horizon has elapsed, since later contacts are still
+// predicted by it. Plans are fetched at the simulator's current step, which
+// is usually a little ahead of the graph; see Lifetime. The fetch runs outside
+// the lock: while one is in flight, other callers get nil without waiting.
+func (c *GraphCache) ContactPlan(g *Graph) (*ContactPlan, error) {
+	c.planMu.Lock()
+	if p := c.plan; p != nil && p.covers(g.SimTime()) {
+		c.planMu.Unlock()
+		return p, nil
+	}
+	if c.planFetching {
+		c.planMu.Unlock()
+		return nil, nil
+	}
+	c.planFetching = true
+	c.planMu.Unlock()
+
+	p, err := CreateContactPlan(c.LifetimeHorizonS)
+
+	c.planMu.Lock()
+	defer c.planMu.Unlock()
+	c.planFetching = false
+	if err != nil {
+		return nil, err
+	}
+	c.plan = p
+	return p, nil
+}
+
+// covers reports whether the plan can predict lifetimes at sim time t: from
+// half a horizon before the plan, which allows for a graph lagging behind the
+// simulator, to half a horizon after it. Anything earlier is taken for a
+// restarted simulator.
+func (p *ContactPlan) covers(t float64) bool {
+	return t >= p.SimTimeS-p.HorizonS/2 && t <= p.SimTimeS+p.HorizonS/2
+}
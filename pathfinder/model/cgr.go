@@ -0,0 +1,177 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+
+	"satellite-coms/pathfinder/internal/httpclient"
+)
+
+// Contact is a predicted window in which two nodes can link.
+type Contact struct {
+	A, B       string
+	StartS     float64
+	EndS       float64
+	MaxRangeKm float64
+}
+
+// ContactPlan is the list of contacts predicted from a simulator step on.
+// Contacts are between nodes, not ports.
+type ContactPlan struct {
+	Step     int
+	SimTimeS float64
+	HorizonS float64
+	Contacts []Contact
+	byNode   map[string][]int
+}
+
+// Hop is one store-carry-forward transmission of a contact route: the data
+// waits at From until DepartS, then crosses the contact window to To.
+type Hop struct {
+	From         string  `json:"from"`
+	To           string  `json:"to"`
+	WindowStartS float64 `json:"window_start_s"`
+	WindowEndS   float64 `json:"window_end_s"`
+	DepartS      float64 `json:"depart_s"`
+	ArriveS      float64 `json:"arrive_s"`
+}
+
+// ContactRoute is a time-respecting route with its earliest delivery time.
+type ContactRoute struct {
+	Path      []string `json:"path"`
+	Hops      []Hop    `json:"hops"`
+	DeliveryS float64  `json:"delivery_s"`
+	DelayS    float64  `json:"delay_s"`
+}
+
+// CreateContactPlan fetches the contact plan over the next horizonS seconds.
+func CreateContactPlan(horizonS float64) (*ContactPlan, error) {
+	data, err := httpclient.FetchContacts(horizonS)
+	if err != nil {
+		return nil, err
+	}
+	p := &ContactPlan{byNode: make(map[string][]int)}
+	if step, ok := data["step"].(float64); ok {
+		p.Step = int(step)
+	}
+	p.SimTimeS, _ = data["sim_time_s"].(float64)
+	p.HorizonS, _ = data["horizon_s"].(float64)
+
+	contacts, _ := data["contacts"].([]interface{})
+	for _, item := range contacts {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		c := Contact{}
+		c.A, _ = obj["a"].(string)
+		c.B, _ = obj["b"].(string)
+		c.StartS, _ = obj["start_s"].(float64)
+		c.EndS, _ = obj["end_s"].(float64)
+		c.MaxRangeKm, _ = obj["max_range_km"].(float64)
+		p.AddContact(c)
+	}
+	return p, nil
+}
+
+// AddContact appends a contact to the plan.
+func (p *ContactPlan) AddContact(c Contact) {
+	if p.byNode == nil {
+		p.byNode = make(map[string][]int)
+	}
+	p.byNode[c.A] = append(p.byNode[c.A], len(p.Contacts))
+	p.byNode[c.B] = append(p.byNode[c.B], len(p.Contacts))
+	p.Contacts = append(p.Contacts, c)
+}
+
+// Routes returns up to k contact routes from start to end, leaving at the
+// plan's sim time, in the order contact graph routing finds them: each route
+// has the earliest delivery time once the contact limiting the previous route,
+// the one that closes first, is excluded.
+func (p *ContactPlan) Routes(start, end string, k int, restricted map[string]bool) []ContactRoute {
+	var routes []ContactRoute
+	excluded := make(map[int]bool)
+	for len(routes) < k {
+		route, used, found := p.earliestArrival(start, end, excluded, restricted)
+		if !found {
+			break
+		}
+		routes = append(routes, route)
+		if len(used) == 0 {
+			break
+		}
+		limiting := used[0]
+		for _, c := range used {
+			if p.Contacts[c].EndS < p.Contacts[limiting].EndS {
+				limiting = c
+			}
+		}
+		excluded[limiting] = true
+	}
+	return routes
+}
+
+// earliestArrival is a time-dependent Dijkstra: data may wait at a node for a
+// contact to open, and crosses it after the one-way light time at its
+// longest range. It returns the route and the contacts it uses.
+func (p *ContactPlan) earliestArrival(start, end string, excluded map[int]bool, restricted map[string]bool) (ContactRoute, []int, bool) {
+	arrival := map[string]float64{start: p.SimTimeS}
+	via := map[string]int{}
+	depart := map[string]float64{}
+	prev := map[string]string{}
+	visited := map[string]bool{}
+	queue := &pathQueue{{node: start, priority: p.SimTimeS}}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queueItem)
+		if visited[current.node] {
+			continue
+		}
+		visited[current.node] = true
+		if current.node == end {
+			break
+		}
+
+		for _, ci := range p.byNode[current.node] {
+			c := p.Contacts[ci]
+			next := c.B
+			if next == current.node {
+				next = c.A
+			}
+			if excluded[ci] || visited[next] || (next != end && restricted[next]) {
+				continue
+			}
+			leave := math.Max(current.priority, c.StartS)
+			if leave > c.EndS {
+				continue
+			}
+			arrive := leave + c.MaxRangeKm/SpeedOfLightKmS
+			if best, seen := arrival[next]; !seen || arrive < best {
+				arrival[next] = arrive
+				via[next], depart[next], prev[next] = ci, leave, current.node
+				heap.Push(queue, queueItem{node: next, priority: arrive})
+			}
+		}
+	}
+	if !visited[end] {
+		return ContactRoute{}, nil, false
+	}
+
+	route := ContactRoute{Path: buildPath(prev, start, end), DeliveryS: arrival[end]}
+	route.DelayS = route.DeliveryS - p.SimTimeS
+	var used []int
+	for i := 1; i < len(route.Path); i++ {
+		to := route.Path[i]
+		c := p.Contacts[via[to]]
+		used = append(used, via[to])
+		route.Hops = append(route.Hops, Hop{
+			From:         route.Path[i-1],
+			To:           to,
+			WindowStartS: c.StartS,
+			WindowEndS:   c.EndS,
+			DepartS:      depart[to],
+			ArriveS:      arrival[to],
+		})
+	}
+	return route, used, true
+}
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PathHop is one vertex of a path. Distance and latency describe the link
+// from the previous vertex and are omitted for the first vertex or when the
+// link has no known geometry.
+type PathHop struct {
+	Vertex     string   `json:"vertex"`
+	Node       string   `json:"node"`
+	Port       int      `json:"port,omitempty"`
+	Portgen    int      `json:"portgen"`
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+	LatencyMs  *float64 `json:"latency_ms,omitempty"`
+}
+
+// PathReport breaks a path down hop by hop. BottleneckHop indexes the hop
+// whose incoming link has the lowest capacity; DelayMs is the total
+// propagation latency.
+type PathReport struct {
+	Hops          []PathHop `json:"hops"`
+	TotalHops     int       `json:"total_hops"`
+	BottleneckHop int       `json:"bottleneck_hop"`
+	DelayMs       float64   `json:"delay_ms"`
+}
+
+// Explanation tells why a strategy returned its path: what the restrictions
+// excluded and how the alternatives compared. Without a path, NoPath says why
+// and the alternatives are the routes that exist but were not usable.
+type Explanation struct {
+	NoPath       string        `json:"no_path,omitempty"`
+	Excluded     []Exclusion   `json:"excluded"`
+	Alternatives []Alternative `json:"alternatives"`
+}
+
+// Exclusion is one restricted vertex and its effect on the search.
+type Exclusion struct {
+	Vertex string `json:"vertex"`
+	Reason string `json:"reason"`
+}
+
+// Alternative is another path between the same ends and why it lost.
+type Alternative struct {
+	Path   []string `json:"path"`
+	Reason string   `json:"reason"`
+}
+
+// Report breaks a path down hop by hop.
+func (g *Graph) Report(path []string) PathReport {
+	r := PathReport{TotalHops: len(path) - 1}
+	narrowest := math.MaxInt
+	for i, v := range path {
+		hop := PathHop{Vertex: v, Node: g.node(v), Portgen: g.portgen[v]}
+		if at := strings.LastIndex(v, ":port"); at >= 0 {
+			hop.Port, _ = strconv.Atoi(v[at+len(":port"):])
+		}
+		if i > 0 {
+			if e, ok := g.links[linkKey(g.node(path[i-1]), hop.Node)]; ok {
+				hop.DistanceKm, hop.LatencyMs = &e.DistanceKm, &e.LatencyMs
+				r.DelayMs += e.LatencyMs
+			}
+			if c := g.Edge(path[i-1], v).Capacity; c < narrowest {
+				narrowest, r.BottleneckHop = c, i
+			}
+		}
+		r.Hops = append(r.Hops, hop)
+	}
+	return r
+}
+
+// Bottleneck returns the lowest capacity along a path, counting the portgen of
+// the start like WidestPath does.
+func (g *Graph) Bottleneck(path []string) int {
+	if len(path) == 0 {
+		return 0
+	}
+	b := g.portgen[path[0]]
+	for i := 1; i < len(path); i++ {
+		b = min(b, g.Edge(path[i-1], path[i]).Capacity)
+	}
+	return b
+}
+
+// Latency returns the total propagation latency of a path.
+func (g *Graph) Latency(path []string) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += g.Edge(path[i-1], path[i]).LatencyMs
+	}
+	return total
+}
+
+// maxAlternatives bounds the alternatives an explanation compares.
+const maxAlternatives = 5
+
+// Explain describes the restrictions and the losing alternatives of a path
+// found by s, or of its failure to find one when path is nil. Alternatives are
+// the paths of the other strategies, the best unrestricted path and the next
+// loop-free paths by latency.
+func (g *Graph) Explain(s Strategy, start, end string, restricted map[string]bool, path []string) Explanation {
+	ex := Explanation{Excluded: []Exclusion{}, Alternatives: []Alternative{}}
+
+	unrestricted, _ := s.Path(g, start, end, nil)
+	var vertices []string
+	for v := range restricted {
+		vertices = append(vertices, v)
+	}
+	sort.Strings(vertices)
+	for _, v := range vertices {
+		reason := "restricted, not on the best unrestricted path"
+		switch {
+		case v == start || v == end:
+			reason = "restricted, but kept as the start or end"
+		case g.adj[v] == nil:
+			reason = "restricted, but not in the graph"
+		case contains(unrestricted, v):
+			reason = "restricted, and on the best unrestricted path"
+		}
+		ex.Excluded = append(ex.Excluded, Exclusion{Vertex: v, Reason: reason})
+	}
+
+	var candidates [][]string
+	for _, name := range StrategyNames() {
+		if other, ok := LookupStrategy(name); ok && name != s.Name() {
+			if p, found := other.Path(g, start, end, restricted); found {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+	if unrestricted != nil {
+		candidates = append(candidates, unrestricted)
+	}
+	for _, p := range g.KShortestPaths(start, end, maxAlternatives, nil, WeightLatency) {
+		candidates = append(candidates, p.Path)
+	}
+
+	seen := map[string]bool{strings.Join(path, ","): true}
+	objective, scored := s.(Objective)
+	for _, p := range candidates {
+		key := strings.Join(p, ",")
+		if seen[key] || len(ex.Alternatives) >= maxAlternatives {
+			continue
+		}
+		seen[key] = true
+
+		var reason string
+		if v := restrictedVertex(p, start, end, restricted); v != "" {
+			reason = fmt.Sprintf("uses restricted vertex %s", v)
+		} else if path == nil {
+			// Nothing was chosen to compare against
+			reason = fmt.Sprintf("exists, but %s does not use it", s.Name())
+			if scored {
+				reason = fmt.Sprintf("exists with %s, but %s does not use it", objective.Describe(objective.Cost(g, p)), s.Name())
+			}
+		} else if scored {
+			alt, chosen := objective.Cost(g, p), objective.Cost(g, path)
+			if alt > chosen {
+				reason = fmt.Sprintf("%s, worse than %s", objective.Describe(alt), objective.Describe(chosen))
+			} else {
+				reason = fmt.Sprintf("ties at %s; the search reached the chosen path first", objective.Describe(alt))
+			}
+		} else {
+			reason = fmt.Sprintf("not chosen by %s", s.Name())
+		}
+		ex.Alternatives = append(ex.Alternatives, Alternative{Path: p, Reason: reason})
+	}
+
+	if path == nil {
+		switch {
+		case len(candidates) == 0:
+			ex.NoPath = "start and end are not connected, even without restrictions"
+		case unrestricted != nil:
+			ex.NoPath = "every usable path crosses a restricted vertex"
+		default:
+			ex.NoPath = fmt.Sprintf("no path %s can use exists, even without restrictions", s.Name())
+		}
+	}
+	return ex
+}
+
+func restrictedVertex(path []string, start, end string, restricted map[string]bool) string {
+	for _, v := range path {
+		if v != start && v != end && restricted[v] {
+			return v
+		}
+	}
+	return ""
+}
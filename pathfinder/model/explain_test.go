@@ -0,0 +1,47 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainWithoutPath(t *testing.T) {
+	widest, _ := LookupStrategy("widest")
+	g := testGraph(nil, []testLink{{"S", "M", 1}, {"M", "T", 1}, {"S", "A", 1}, {"T", "B", 1}})
+
+	restricted := map[string]bool{"M:port1": true}
+	if _, found := widest.Path(g, "S:port1", "T:port1", restricted); found {
+		t.Fatal("found a path through the only restricted vertex")
+	}
+	ex := g.Explain(widest, "S:port1", "T:port1", restricted, nil)
+	if ex.NoPath != "every usable path crosses a restricted vertex" {
+		t.Errorf("no_path %q", ex.NoPath)
+	}
+	if len(ex.Alternatives) != 1 || ex.Alternatives[0].Reason != "uses restricted vertex M:port1" {
+		t.Errorf("alternatives %+v, want the path through M", ex.Alternatives)
+	}
+	for _, a := range ex.Alternatives {
+		if strings.Contains(a.Reason, "worse") || strings.Contains(a.Reason, "ties") {
+			t.Errorf("alternative %v compared against a path that does not exist: %s", a.Path, a.Reason)
+		}
+	}
+
+	ex = g.Explain(widest, "A:port1", "B:port1", map[string]bool{"S:port1": true, "M:port1": true, "T:port1": true}, nil)
+	if !strings.HasPrefix(ex.NoPath, "every usable path") || len(ex.Alternatives) == 0 {
+		t.Errorf("got %+v", ex)
+	}
+
+	// Widest never uses links without capacity
+	g.SetLink("S", "M", Edge{LatencyMs: 1})
+	ex = g.Explain(widest, "S:port1", "T:port1", nil, nil)
+	if ex.NoPath != "no path widest can use exists, even without restrictions" ||
+		len(ex.Alternatives) == 0 || ex.Alternatives[0].Reason != "exists with bottleneck capacity 0, but widest does not use it" {
+		t.Errorf("got %+v", ex)
+	}
+
+	g = testGraph(nil, []testLink{{"S", "A", 1}, {"T", "B", 1}})
+	ex = g.Explain(widest, "S:port1", "T:port1", nil, nil)
+	if ex.NoPath != "start and end are not connected, even without restrictions" || len(ex.Alternatives) != 0 {
+		t.Errorf("got %+v", ex)
+	}
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Export formats.
+const (
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+	FormatJSON    = "json"
+)
+
+// ExportContentTypes maps each export format to its media type.
+var ExportContentTypes = map[string]string{
+	FormatDOT:     "text/vnd.graphviz",
+	FormatGraphML: "application/graphml+xml",
+	FormatJSON:    "application/json",
+}
+
+// ExportNode is a vertex with its attributes. Port is 0 at node level.
+type ExportNode struct {
+	ID      string  `json:"id"`
+	Label   string  `json:"label"`
+	Node    string  `json:"node"`
+	Port    int     `json:"port,omitempty"`
+	Portgen int     `json:"portgen"`
+	Kind    string  `json:"kind"`
+	XKm     float64 `json:"x_km"`
+	YKm     float64 `json:"y_km"`
+}
+
+// ExportEdge is an edge with its weights.
+type ExportEdge struct {
+	Source     string  `json:"source"`
+	Target     string  `json:"target"`
+	DistanceKm float64 `json:"distance_km"`
+	LatencyMs  float64 `json:"latency_ms"`
+	Capacity   int     `json:"capacity"`
+}
+
+// Export writes the graph at the given level in the given format. The JSON
+// format is the node-link layout networkx reads with node_link_graph.
+func (g *Graph) Export(w io.Writer, format, level string) error {
+	nodes, edges := g.exportElements(level)
+	switch format {
+	case FormatDOT:
+		return writeDOT(w, nodes, edges)
+	case FormatGraphML:
+		return writeGraphML(w, nodes, edges)
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"directed":   false,
+			"multigraph": false,
+			"graph":      map[string]interface{}{"step": g.step, "sim_time_s": g.simTime, "level": level},
+			"nodes":      nodes,
+			"links":      edges,
+		})
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// exportElements lists the vertices and edges at the given level, sorted.
+func (g *Graph) exportElements(level string) ([]ExportNode, []ExportEdge) {
+	u := g.undirected(level)
+	nodes := make([]ExportNode, 0, len(u.names))
+	for _, v := range u.names {
+		node := g.node(v)
+		n := ExportNode{ID: v, Label: g.name[node], Node: node, Portgen: g.portgen[v], Kind: "satellite"}
+		if n.Label == "" {
+			n.Label = node
+		}
+		if at := strings.LastIndex(v, ":port"); at >= 0 {
+			n.Port, _ = strconv.Atoi(v[at+len(":port"):])
+			n.Label = fmt.Sprintf("%s (port %d)", n.Label, n.Port)
+		}
+		if isGround(node) {
+			n.Kind = "ground"
+		}
+		n.XKm, n.YKm = g.position[node][0], g.position[node][1]
+		nodes = append(nodes, n)
+	}
+
+	edges := []ExportEdge{}
+	for i, neighbors := range u.adj {
+		for _, j := range neighbors {
+			if j <= i {
+				continue
+			}
+			e := g.Edge(u.names[i], u.names[j])
+			edges = append(edges, ExportEdge{
+				Source:     u.names[i],
+				Target:     u.names[j],
+				DistanceKm: e.DistanceKm,
+				LatencyMs:  e.LatencyMs,
+				Capacity:   e.Capacity,
+			})
+		}
+	}
+	return nodes, edges
+}
+
+// writeDOT writes the graph in Graphviz's DOT language. node is a DOT
+// keyword, so the node attribute is named node_id there; numbers are written
+// without exponents, which DOT numerals do not allow.
+func writeDOT(w io.Writer, nodes []ExportNode, edges []ExportEdge) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "graph orbital {")
+	for _, n := range nodes {
+		fmt.Fprintf(b, "  %s [label=%s, node_id=%s, port=%d, portgen=%d, kind=%s, x_km=%s, y_km=%s];\n",
+			dotQuote(n.ID), dotQuote(n.Label), dotQuote(n.Node), n.Port, n.Portgen, dotQuote(n.Kind), dotNumber(n.XKm), dotNumber(n.YKm))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "  %s -- %s [distance_km=%s, latency_ms=%s, capacity=%d];\n",
+			dotQuote(e.Source), dotQuote(e.Target), dotNumber(e.DistanceKm), dotNumber(e.LatencyMs), e.Capacity)
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// dotQuote returns s as a DOT double-quoted string. Backslashes are doubled
+// so none escapes the closing quote.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func dotNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeGraphML(w io.Writer, nodes []ExportNode, edges []ExportEdge) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, key := range [][3]string{
+		{"node", "label", "string"}, {"node", "node", "string"}, {"node", "port", "int"},
+		{"node", "portgen", "int"}, {"node", "kind", "string"}, {"node", "x_km", "double"}, {"node", "y_km", "double"},
+		{"edge", "distance_km", "double"}, {"edge", "latency_ms", "double"}, {"edge", "capacity", "int"},
+	} {
+		fmt.Fprintf(b, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", key[1], key[0], key[1], key[2])
+	}
+	fmt.Fprintln(b, `  <graph id="orbital" edgedefault="undirected">`)
+	for _, n := range nodes {
+		fmt.Fprintf(b, `    <node id="%s">`, escapeXML(n.ID))
+		fmt.Fprintf(b, `<data key="label">%s</data><data key="node">%s</data><data key="port">%d</data>`, escapeXML(n.Label), escapeXML(n.Node), n.Port)
+		fmt.Fprintf(b, `<data key="portgen">%d</data><data key="kind">%s</data>`, n.Portgen, n.Kind)
+		fmt.Fprintf(b, `<data key="x_km">%g</data><data key="y_km">%g</data></node>`+"\n", n.XKm, n.YKm)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, `    <edge source="%s" target="%s">`, escapeXML(e.Source), escapeXML(e.Target))
+		fmt.Fprintf(b, `<data key="distance_km">%g</data><data key="latency_ms">%g</data><data key="capacity">%d</data></edge>`+"\n", e.DistanceKm, e.LatencyMs, e.Capacity)
+	}
+	fmt.Fprintln(b, "  </graph>\n</graphml>")
+	return b.Flush()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// dotKeywords may not be used as bare IDs; DOT matches them in any case.
+var dotKeywords = map[string]bool{"node": true, "edge": true, "graph": true, "digraph": true, "subgraph": true, "strict": true}
+
+// dotTokens splits the subset of DOT the exporter writes into IDs (bare,
+// numeral or quoted) and punctuation.
+func dotTokens(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, "--")
+			i += 2
+		case strings.ContainsRune("{}[]=,;", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] == '-' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// validDOTID reports whether a token is a DOT ID: a quoted string, a numeral,
+// or a bare identifier that is not a keyword.
+func validDOTID(tok string) bool {
+	if strings.HasPrefix(tok, `"`) {
+		return true
+	}
+	numeral := tok != "" && tok != "-" && tok != "." && tok != "-."
+	for i, r := range tok {
+		if !(unicode.IsDigit(r) || r == '.' && strings.Count(tok, ".") == 1 || r == '-' && i == 0) {
+			numeral = false
+		}
+	}
+	if numeral {
+		return true
+	}
+	if tok == "" || unicode.IsDigit(rune(tok[0])) || dotKeywords[strings.ToLower(tok)] {
+		return false
+	}
+	for _, r := range tok {
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDOT checks the exporter's output against the DOT grammar:
+// graph ID { (ID | ID -- ID) [ID=ID, ...]; ... } and returns the attributes
+// of every vertex and edge statement.
+func parseDOT(src string) ([]map[string]string, error) {
+	tokens, err := dotTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	expect := func(want string) error {
+		if got := next(); got != want {
+			return fmt.Errorf("token %d: got %q, want %q", pos, got, want)
+		}
+		return nil
+	}
+	id := func() (string, error) {
+		tok := next()
+		if !validDOTID(tok) {
+			return "", fmt.Errorf("token %d: %q is not a DOT ID", pos, tok)
+		}
+		return tok, nil
+	}
+
+	if err := expect("graph"); err != nil {
+		return nil, err
+	}
+	if _, err := id(); err != nil {
+		return nil, err
+	}
+	if err := expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []map[string]string
+	for pos < len(tokens) && tokens[pos] != "}" {
+		if _, err := id(); err != nil {
+			return nil, err
+		}
+		if pos < len(tokens) && tokens[pos] == "--" {
+			next()
+			if _, err := id(); err != nil {
+				return nil, err
+			}
+		}
+		attrs := make(map[string]string)
+		if err := expect("["); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := id()
+			if err != nil {
+				return nil, err
+			}
+			if err := expect("="); err != nil {
+				return nil, err
+			}
+			value, err := id()
+			if err != nil {
+				return nil, err
+			}
+			attrs[name] = value
+			if tok := next(); tok == "]" {
+				break
+			} else if tok != "," {
+				return nil, fmt.Errorf("token %d: got %q in attribute list", pos, tok)
+			}
+		}
+		if err := expect(";"); err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, attrs)
+	}
+	if err := expect("}"); err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("%d tokens after the graph", len(tokens)-pos)
+	}
+	return stmts, nil
+}
+
+func TestExportDOTParses(t *testing.T) {
+	g := testGraph(map[string]int{"sat_b": 2}, []testLink{{"srv_a", "sat_b", 3.2}, {"sat_b", "sat_c", 0.00001}})
+	g.name["srv_a"] = `Home "HQ" \`
+	g.position["sat_b"] = [2]float64{-12345678.5, 1e21}
+
+	for _, level := range []string{"port", "node"} {
+		var buf bytes.Buffer
+		if err := g.Export(&buf, FormatDOT, level); err != nil {
+			t.Fatal(err)
+		}
+		stmts, err := parseDOT(buf.String())
+		if err != nil {
+			t.Fatalf("%s level: %v\n%s", level, err, buf.String())
+		}
+		nodes, edges := g.exportElements(level)
+		if len(stmts) != len(nodes)+len(edges) {
+			t.Errorf("%s level: %d statements, want %d vertices and %d edges", level, len(stmts), len(nodes), len(edges))
+		}
+		for _, attrs := range stmts[:len(nodes)] {
+			if attrs["node_id"] == "" || attrs["kind"] == "" {
+				t.Errorf("%s level: vertex attributes %v lack node_id or kind", level, attrs)
+			}
+		}
+	}
+}
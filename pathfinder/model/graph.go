@@ -2,23 +2,60 @@ package model
 
 import (
 	"fmt"
-	"log"
+	"math"
 
 	"satellite-coms/pathfinder/internal/httpclient"
 )
 
+// SpeedOfLightKmS is used to turn link distances into latencies.
+const SpeedOfLightKmS = 299792.458
+
+// Graph is the port-level topology of one step. Vertices are ports named
+// "<node id>:port<n>"; edges between the ports of two nodes share the weights
+// of the link between those nodes.
 type Graph struct {
 	adj     map[string][]string
 	portgen map[string]int
+	step    int
+	simTime float64
+
+	// nodeOf maps a port to its node, links holds the weights of every
+	// node pair, position the node positions in km and name their names
+	nodeOf   map[string]string
+	links    map[[2]string]Edge
+	position map[string][2]float64
+	name     map[string]string
+}
+
+// Edge holds the weights of a link. Capacity is the link's portgen, the lower
+// of both ends.
+type Edge struct {
+	DistanceKm float64 `json:"distance_km"`
+	LatencyMs  float64 `json:"latency_ms"`
+	Capacity   int     `json:"capacity"`
 }
 
 func NewGraph() *Graph {
 	return &Graph{
-		adj:     make(map[string][]string),
-		portgen: make(map[string]int),
+		adj:      make(map[string][]string),
+		portgen:  make(map[string]int),
+		nodeOf:   make(map[string]string),
+		links:    make(map[[2]string]Edge),
+		position: make(map[string][2]float64),
+		name:     make(map[string]string),
 	}
 }
 
+// Step returns the simulator step the graph was built from.
+func (g *Graph) Step() int {
+	return g.step
+}
+
+// SimTime returns the sim time of the graph's step in seconds.
+func (g *Graph) SimTime() float64 {
+	return g.simTime
+}
+
 func (g *Graph) AddEdges(node string, neighbors []string) {
 	for _, neighbor := range neighbors {
 		if !contains(g.adj[node], neighbor) {
@@ -30,6 +67,35 @@ func (g *Graph) AddEdges(node string, neighbors []string) {
 	}
 }
 
+// SetLink sets the weights of every edge between the ports of nodes a and b.
+func (g *Graph) SetLink(a, b string, e Edge) {
+	g.links[linkKey(a, b)] = e
+}
+
+// Edge returns the weights of the edge between two vertices. Without a known
+// link, distance and latency are zero and capacity is the lower portgen.
+func (g *Graph) Edge(u, v string) Edge {
+	if e, ok := g.links[linkKey(g.node(u), g.node(v))]; ok {
+		return e
+	}
+	return Edge{Capacity: min(g.portgen[u], g.portgen[v])}
+}
+
+// node returns the node a vertex belongs to; node-level vertices are their own node.
+func (g *Graph) node(v string) string {
+	if n, ok := g.nodeOf[v]; ok {
+		return n
+	}
+	return v
+}
+
+func linkKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
 func contains(slice []string, val string) bool {
 	for _, item := range slice {
 		if item == val {
@@ -45,85 +111,33 @@ func (g *Graph) Print() {
 	}
 }
 
-func (g *Graph) WidestPath(start, end string, restricted map[string]bool) ([]string, bool) {
-	if start == end {
-		return []string{start}, true
-	}
-
-	type state struct {
-		node  string
-		minPG int
-	}
-
-	maxPortgen := map[string]int{}
-	prev := map[string]string{}
-	visited := map[string]bool{}
-	queue := []state{{node: start, minPG: g.portgen[start]}}
-	maxPortgen[start] = g.portgen[start]
-
-	for len(queue) > 0 {
-		bestIdx := 0
-		for i := 1; i < len(queue); i++ {
-			if queue[i].minPG > queue[bestIdx].minPG {
-				bestIdx = i
-			}
-		}
-		current := queue[bestIdx]
-		queue = append(queue[:bestIdx], queue[bestIdx+1:]...)
-
-		if visited[current.node] {
-			continue
-		}
-		visited[current.node] = true
-
-		if current.node == end {
-			path := []string{end}
-			for node := end; node != start; {
-				node = prev[node]
-				path = append([]string{node}, path...)
-			}
-			return path, true
-		}
-
-		for _, neighbor := range g.adj[current.node] {
-			if neighbor != start && neighbor != end && restricted[neighbor] {
-				continue
-			}
-			if visited[neighbor] {
-				continue
-			}
-
-			edgePG := min(g.portgen[current.node], g.portgen[neighbor])
-			newMin := min(current.minPG, edgePG)
-
-			if newMin > maxPortgen[neighbor] {
-				maxPortgen[neighbor] = newMin
-				prev[neighbor] = current.node
-				queue = append(queue, state{node: neighbor, minPG: newMin})
-			}
-		}
-	}
-	return nil, false
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
+// --- Build the graph using Redis + Consul services ---
+func CreateGraph() (*Graph, error) {
+	// Nodes and visibility come from one snapshot so they describe the same step
+	snapshot, err := httpclient.FetchSnapshot(-1)
+	if err != nil {
+		return nil, err
 	}
-	return b
+	return graphFromSnapshot(snapshot)
 }
 
-// --- Build the graph using Redis + Consul services ---
-func CreateGraph() *Graph {
+func graphFromSnapshot(snapshot map[string]interface{}) (*Graph, error) {
 	g := NewGraph()
 
-	// Fetch nodes and visibility from the simulator service
-	nodes := httpclient.FetchNodes()
-	matrix := httpclient.FetchVisibility()
+	nodes, _ := snapshot["nodes"].([]interface{})
+	adjacency, _ := snapshot["visibility"].(map[string]interface{})
+	if step, ok := snapshot["step"].(float64); ok {
+		g.step = int(step)
+	}
+	g.simTime, _ = snapshot["sim_time_s"].(float64)
 
-	for idx, node := range nodes {
+	// Number of ports per node
+	ports := make(map[string]int)
+	var ids []string
+	for _, node := range nodes {
 		obj := node.(map[string]interface{})
 		id := obj["id"].(string)
+		ids = append(ids, id)
 
 		// Store portgen for the base node
 		if portgenVal, ok := obj["portgen"].(float64); ok {
@@ -132,41 +146,55 @@ func CreateGraph() *Graph {
 			g.portgen[id] = -1
 		}
 
-		// Number of ports per node
-		ports := 1
+		ports[id] = 1
 		if portsVal, ok := obj["ports"].(float64); ok && int(portsVal) > 0 {
-			ports = int(portsVal)
+			ports[id] = int(portsVal)
 		}
 
-		row, ok := matrix[idx].([]interface{})
+		g.name[id], _ = obj["name"].(string)
+		x, _ := obj["x_km"].(float64)
+		y, _ := obj["y_km"].(float64)
+		g.position[id] = [2]float64{x, y}
+	}
+
+	// Create port nodes and connect edges
+	for _, id := range ids {
+		row, ok := adjacency[id].([]interface{})
 		if !ok {
-			log.Fatalf("Row %d is not a []interface{}", idx)
+			return nil, fmt.Errorf("no visibility for node %s", id)
 		}
 
-		// Create port nodes and connect edges
-		for portNum := 1; portNum <= ports; portNum++ {
+		for portNum := 1; portNum <= ports[id]; portNum++ {
 			nodePort := fmt.Sprintf("%s:port%d", id, portNum)
 
 			var neighbors []string
-			for j, val := range row {
-				if val == true {
-					neighborObj := nodes[j].(map[string]interface{})
-					neighborID := neighborObj["id"].(string)
-					neighborPorts := 1
-					if npVal, ok := neighborObj["ports"].(float64); ok && int(npVal) > 0 {
-						neighborPorts = int(npVal)
-					}
-					for np := 1; np <= neighborPorts; np++ {
-						neighborPort := fmt.Sprintf("%s:port%d", neighborID, np)
-						neighbors = append(neighbors, neighborPort)
-					}
+			for _, val := range row {
+				neighborID, _ := val.(string)
+				if portNum == 1 {
+					g.setLinkWeights(id, neighborID)
+				}
+				for np := 1; np <= ports[neighborID]; np++ {
+					neighbors = append(neighbors, fmt.Sprintf("%s:port%d", neighborID, np))
 				}
 			}
 
 			g.AddEdges(nodePort, neighbors)
 			g.portgen[nodePort] = g.portgen[id]
+			g.nodeOf[nodePort] = id
 		}
 	}
 
-	return g
+	return g, nil
+}
+
+// setLinkWeights derives the weights of the link between two nodes from their
+// positions and portgens.
+func (g *Graph) setLinkWeights(a, b string) {
+	pa, pb := g.position[a], g.position[b]
+	d := math.Hypot(pb[0]-pa[0], pb[1]-pa[1])
+	g.SetLink(a, b, Edge{
+		DistanceKm: d,
+		LatencyMs:  d / SpeedOfLightKmS * 1000,
+		Capacity:   min(g.portgen[a], g.portgen[b]),
+	})
 }
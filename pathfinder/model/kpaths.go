@@ -0,0 +1,391 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Disjointness of the alternative paths returned by DisjointPaths.
+const (
+	DisjointLink = "link"
+	DisjointNode = "node"
+)
+
+// Additive weights alternative paths are ranked by.
+const (
+	WeightLatency = "latency"
+	WeightHops    = "hops"
+)
+
+// RankedPath is one alternative route with its figures. The bottleneck portgen
+// is the lowest portgen of the ports on the path.
+type RankedPath struct {
+	Path              []string  `json:"path"`
+	Hops              int       `json:"hops"`
+	BottleneckPortgen int       `json:"bottleneck_portgen"`
+	LatencyMs         float64   `json:"latency_ms"`
+	Lifetime          *Lifetime `json:"lifetime,omitempty"`
+	cost              float64
+}
+
+// edgeWeight returns the named additive edge weight.
+func (g *Graph) edgeWeight(name string) (func(u, v string) float64, bool) {
+	switch name {
+	case "", WeightLatency:
+		return func(u, v string) float64 { return g.Edge(u, v).LatencyMs }, true
+	case WeightHops:
+		return func(_, _ string) float64 { return 1 }, true
+	}
+	return nil, false
+}
+
+func (g *Graph) rank(path []string, weight func(u, v string) float64) RankedPath {
+	p := RankedPath{Path: path, Hops: len(path) - 1, BottleneckPortgen: g.portgen[path[0]]}
+	for i := 1; i < len(path); i++ {
+		p.BottleneckPortgen = min(p.BottleneckPortgen, g.portgen[path[i]])
+		p.LatencyMs += g.Edge(path[i-1], path[i]).LatencyMs
+		p.cost += weight(path[i-1], path[i])
+	}
+	return p
+}
+
+// nodeLevel returns a view of g in which every port other than start and end
+// is merged into its node, so routes that only differ in the ports they cross
+// become one path. The other ports of the start and end nodes and restricted
+// ports are left out. port maps every merged node to the port a path through
+// it is reported with, its lowest unrestricted port.
+func (g *Graph) nodeLevel(start, end string, restricted map[string]bool) (h *Graph, port map[string]string) {
+	key := func(v string) (string, bool) {
+		if v == start || v == end {
+			return v, true
+		}
+		n := g.node(v)
+		if restricted[v] || n == g.node(start) || n == g.node(end) {
+			return "", false
+		}
+		return n, true
+	}
+
+	var vertices []string
+	for v := range g.adj {
+		vertices = append(vertices, v)
+	}
+	sort.Strings(vertices)
+
+	h = NewGraph()
+	h.step, h.simTime = g.step, g.simTime
+	h.links, h.position, h.name = g.links, g.position, g.name
+	port = make(map[string]string)
+	for _, v := range vertices {
+		kv, ok := key(v)
+		if !ok {
+			continue
+		}
+		if kv == v {
+			h.nodeOf[v] = g.node(v)
+		} else if _, ok := port[kv]; !ok {
+			port[kv] = v
+		}
+		h.portgen[kv] = g.portgen[v]
+		if _, ok := h.adj[kv]; !ok {
+			h.adj[kv] = nil
+		}
+		for _, u := range g.adj[v] {
+			if ku, ok := key(u); ok && ku != kv {
+				h.AddEdges(kv, []string{ku})
+			}
+		}
+	}
+	return h, port
+}
+
+// portPaths reports node-level paths with the ports of g.
+func (g *Graph) portPaths(paths []RankedPath, port map[string]string, weightName string) []RankedPath {
+	weight, _ := g.edgeWeight(weightName)
+	for i, p := range paths {
+		path := make([]string, len(p.Path))
+		for j, v := range p.Path {
+			path[j] = v
+			if pv, ok := port[v]; ok {
+				path[j] = pv
+			}
+		}
+		paths[i] = g.rank(path, weight)
+	}
+	return paths
+}
+
+// KShortestPaths returns up to k loop-free paths through distinct sequences
+// of nodes in increasing order of the named weight, using Yen's algorithm on
+// the node-level view of the graph. It returns nil for an unknown weight.
+func (g *Graph) KShortestPaths(start, end string, k int, restricted map[string]bool, weightName string) []RankedPath {
+	h, port := g.nodeLevel(start, end, restricted)
+	return g.portPaths(h.kShortestPaths(start, end, k, weightName), port, weightName)
+}
+
+func (g *Graph) kShortestPaths(start, end string, k int, weightName string) []RankedPath {
+	weight, ok := g.edgeWeight(weightName)
+	if !ok {
+		return nil
+	}
+	shortest := func(from string, blockedNodes map[string]bool, blockedEdges map[[2]string]bool) ([]string, bool) {
+		return g.bestFirst(from, end, blockedNodes, 0, func(cost float64, u, v string) float64 {
+			if blockedEdges[linkKey(u, v)] {
+				return math.Inf(1)
+			}
+			return cost + weight(u, v)
+		}, nil)
+	}
+
+	first, found := shortest(start, nil, nil)
+	if !found {
+		return nil
+	}
+	accepted := []RankedPath{g.rank(first, weight)}
+	var candidates []RankedPath
+	seen := map[string]bool{strings.Join(first, ","): true}
+
+	for len(accepted) < k {
+		last := accepted[len(accepted)-1].Path
+		for i := 0; i < len(last)-1; i++ {
+			spur, root := last[i], last[:i+1]
+
+			// Leave the root through an edge no accepted path with this root used
+			blockedEdges := make(map[[2]string]bool)
+			for _, p := range accepted {
+				if len(p.Path) > i+1 && equalPaths(p.Path[:i+1], root) {
+					blockedEdges[linkKey(p.Path[i], p.Path[i+1])] = true
+				}
+			}
+			blockedNodes := make(map[string]bool, i)
+			for _, node := range root[:i] {
+				blockedNodes[node] = true
+			}
+
+			spurPath, found := shortest(spur, blockedNodes, blockedEdges)
+			if !found {
+				continue
+			}
+			path := append(append([]string{}, root[:i]...), spurPath...)
+			if key := strings.Join(path, ","); !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, g.rank(path, weight))
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(x, y int) bool { return candidates[x].cost < candidates[y].cost })
+		accepted = append(accepted, candidates[0])
+		candidates = candidates[1:]
+	}
+	return accepted
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// flowArc is an arc of the residual network used by DisjointPaths.
+type flowArc struct {
+	to, rev int
+	cap     int
+	cost    float64
+	edge    bool
+}
+
+// DisjointPaths returns up to k link-disjoint (or, with nodeDisjoint,
+// node-disjoint) paths with the lowest total weight. It generalizes
+// Suurballe's algorithm on the node-level view of the graph: each round
+// augments one unit of flow along the shortest path of the residual network,
+// which may reroute earlier paths, and the flow is finally split into paths.
+// It returns nil for an unknown weight.
+func (g *Graph) DisjointPaths(start, end string, k int, nodeDisjoint bool, restricted map[string]bool, weightName string) []RankedPath {
+	h, port := g.nodeLevel(start, end, restricted)
+	return g.portPaths(h.disjointPaths(start, end, k, nodeDisjoint, weightName), port, weightName)
+}
+
+func (g *Graph) disjointPaths(start, end string, k int, nodeDisjoint bool, weightName string) []RankedPath {
+	weight, ok := g.edgeWeight(weightName)
+	if !ok || start == end {
+		return nil
+	}
+
+	// Every vertex v is split into an in node 2i and an out node 2i+1; the arc
+	// between them limits how many paths may cross v
+	var names []string
+	for v := range g.adj {
+		names = append(names, v)
+	}
+	sort.Strings(names)
+	index := make(map[string]int, len(names))
+	for i, v := range names {
+		index[v] = i
+	}
+	if _, ok := index[start]; !ok {
+		return nil
+	}
+	if _, ok := index[end]; !ok {
+		return nil
+	}
+
+	arcs := make([][]flowArc, 2*len(names))
+	addArc := func(from, to, cap int, cost float64, edge bool) {
+		arcs[from] = append(arcs[from], flowArc{to: to, rev: len(arcs[to]), cap: cap, cost: cost, edge: edge})
+		arcs[to] = append(arcs[to], flowArc{to: from, rev: len(arcs[from]) - 1, cap: 0, cost: -cost})
+	}
+	for i, v := range names {
+		through := k
+		if nodeDisjoint && v != start && v != end {
+			through = 1
+		}
+		addArc(2*i, 2*i+1, through, 0, false)
+		for _, u := range g.adj[v] {
+			if j, ok := index[u]; ok {
+				addArc(2*i+1, 2*j, 1, weight(v, u), true)
+			}
+		}
+	}
+
+	source, sink := 2*index[start], 2*index[end]+1
+	potential := make([]float64, len(arcs))
+	flow := 0
+	for ; flow < k; flow++ {
+		// Dijkstra on reduced costs, which the potentials keep non-negative
+		dist := make([]float64, len(arcs))
+		for i := range dist {
+			dist[i] = math.Inf(1)
+		}
+		prevNode, prevArc := make([]int, len(arcs)), make([]int, len(arcs))
+		dist[source] = 0
+		queue := &flowQueue{{node: source}}
+		for queue.Len() > 0 {
+			current := heap.Pop(queue).(flowItem)
+			if current.dist > dist[current.node] {
+				continue
+			}
+			for a, arc := range arcs[current.node] {
+				if arc.cap == 0 {
+					continue
+				}
+				reduced := math.Max(0, arc.cost+potential[current.node]-potential[arc.to])
+				if d := dist[current.node] + reduced; d < dist[arc.to] {
+					dist[arc.to] = d
+					prevNode[arc.to], prevArc[arc.to] = current.node, a
+					heap.Push(queue, flowItem{node: arc.to, dist: d})
+				}
+			}
+		}
+		if math.IsInf(dist[sink], 1) {
+			break
+		}
+		for i := range potential {
+			if !math.IsInf(dist[i], 1) {
+				potential[i] += dist[i]
+			}
+		}
+		for v := sink; v != source; v = prevNode[v] {
+			arc := &arcs[prevNode[v]][prevArc[v]]
+			arc.cap--
+			arcs[v][arc.rev].cap++
+		}
+	}
+
+	// Collect the used edges, cancelling flow sent both ways over one link
+	used := make(map[int][]int)
+	for from := range arcs {
+		for _, arc := range arcs[from] {
+			if arc.edge && arc.cap == 0 {
+				used[from/2] = append(used[from/2], arc.to/2)
+			}
+		}
+	}
+	for u, vs := range used {
+		for _, v := range vs {
+			if containsInt(used[v], u) {
+				used[v] = without(used[v], u)
+				used[u] = without(used[u], v)
+			}
+		}
+	}
+
+	// Every unit of flow leaves start and, by conservation, reaches end; a
+	// walk that comes back to a vertex went round a zero-cost cycle of flow,
+	// which is cut out
+	var paths []RankedPath
+	for p := 0; p < flow; p++ {
+		walk := []int{index[start]}
+		at := map[int]int{index[start]: 0}
+		for v := index[start]; v != index[end] && len(used[v]) > 0; {
+			next := used[v][0]
+			used[v] = used[v][1:]
+			if i, ok := at[next]; ok {
+				for _, u := range walk[i+1:] {
+					delete(at, u)
+				}
+				walk = walk[:i+1]
+			} else {
+				at[next] = len(walk)
+				walk = append(walk, next)
+			}
+			v = next
+		}
+		if walk[len(walk)-1] != index[end] {
+			continue
+		}
+		path := make([]string, len(walk))
+		for i, v := range walk {
+			path[i] = names[v]
+		}
+		paths = append(paths, g.rank(path, weight))
+	}
+	sort.SliceStable(paths, func(x, y int) bool { return paths[x].cost < paths[y].cost })
+	return paths
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func without(values []int, v int) []int {
+	for i, x := range values {
+		if x == v {
+			return append(values[:i:i], values[i+1:]...)
+		}
+	}
+	return values
+}
+
+type flowItem struct {
+	node int
+	dist float64
+}
+
+// flowQueue is a binary min-heap of residual network nodes by distance.
+type flowQueue []flowItem
+
+func (q flowQueue) Len() int            { return len(q) }
+func (q flowQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q flowQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x interface{}) { *q = append(*q, x.(flowItem)) }
+func (q *flowQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
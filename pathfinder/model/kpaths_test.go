@@ -0,0 +1,222 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testLink is an edge of a node-level test topology with its latency.
+type testLink struct {
+	a, b      string
+	latencyMs float64
+}
+
+// testGraph builds the port-level graph of a topology. Nodes not listed in
+// ports have one port.
+func testGraph(ports map[string]int, links []testLink) *Graph {
+	g := NewGraph()
+	portsOf := func(id string) []string {
+		n := max(ports[id], 1)
+		var out []string
+		for p := 1; p <= n; p++ {
+			v := fmt.Sprintf("%s:port%d", id, p)
+			g.nodeOf[v] = id
+			g.portgen[v] = 1
+			out = append(out, v)
+		}
+		return out
+	}
+	for _, l := range links {
+		g.SetLink(l.a, l.b, Edge{LatencyMs: l.latencyMs, Capacity: 1})
+		for _, v := range portsOf(l.a) {
+			g.AddEdges(v, portsOf(l.b))
+		}
+	}
+	return g
+}
+
+// nodePaths maps ranked paths to their node sequences, joined by dashes.
+func nodePaths(g *Graph, paths []RankedPath) []string {
+	var out []string
+	for _, p := range paths {
+		var nodes []string
+		for _, v := range p.Path {
+			nodes = append(nodes, g.node(v))
+		}
+		out = append(out, strings.Join(nodes, "-"))
+	}
+	return out
+}
+
+func checkPortPaths(t *testing.T, g *Graph, paths []RankedPath) {
+	t.Helper()
+	for _, p := range paths {
+		for i := 1; i < len(p.Path); i++ {
+			if !contains(g.adj[p.Path[i-1]], p.Path[i]) {
+				t.Errorf("path %v uses %s - %s, which is not an edge", p.Path, p.Path[i-1], p.Path[i])
+			}
+		}
+	}
+}
+
+// ladder is two rails A1-A2-A3 and B1-B2-B3 joined by rungs.
+var ladder = []testLink{
+	{"A1", "A2", 1}, {"A2", "A3", 1},
+	{"B1", "B2", 1}, {"B2", "B3", 1},
+	{"A1", "B1", 1}, {"A2", "B2", 1}, {"A3", "B3", 1},
+}
+
+// trap is Suurballe's trap: removing the shortest path S-A-B-T leaves no
+// second path, yet S-A-D-T and S-C-B-T are disjoint.
+var trap = []testLink{
+	{"S", "A", 1}, {"A", "B", 1}, {"B", "T", 1},
+	{"A", "D", 2}, {"D", "T", 2},
+	{"S", "C", 2}, {"C", "B", 3},
+}
+
+// bowtie has two link-disjoint paths from S to T that both cross M.
+var bowtie = []testLink{
+	{"S", "M", 1}, {"M", "T", 1},
+	{"S", "A", 1}, {"A", "M", 1}, {"M", "B", 1}, {"B", "T", 1},
+}
+
+func TestKShortestPaths(t *testing.T) {
+	cases := []struct {
+		name       string
+		ports      map[string]int
+		links      []testLink
+		start, end string
+		k          int
+		weight     string
+		restricted []string
+		want       []string
+	}{
+		{
+			name: "ladder by hops", links: ladder, start: "A1:port1", end: "B3:port1", k: 5, weight: WeightHops,
+			want: []string{"A1-A2-A3-B3", "A1-A2-B2-B3", "A1-B1-B2-B3", "A1-B1-B2-A2-A3-B3"},
+		},
+		{
+			name: "trap by latency", links: trap, start: "S:port1", end: "T:port1", k: 3, weight: WeightLatency,
+			want: []string{"S-A-B-T", "S-A-D-T", "S-C-B-T"},
+		},
+		{
+			name: "trap stops at k", links: trap, start: "S:port1", end: "T:port1", k: 2, weight: WeightLatency,
+			want: []string{"S-A-B-T", "S-A-D-T"},
+		},
+		{
+			name: "ports of a node are one route", ports: map[string]int{"S": 2, "M": 3, "T": 2},
+			links: []testLink{{"S", "M", 1}, {"M", "T", 1}}, start: "S:port1", end: "T:port2", k: 5,
+			want: []string{"S-M-T"},
+		},
+		{
+			name: "restricted node", links: ladder, start: "A1:port1", end: "B3:port1", k: 5, weight: WeightHops,
+			restricted: []string{"A2:port1"}, want: []string{"A1-B1-B2-B3"},
+		},
+		{
+			name: "unreachable", links: ladder, start: "A1:port1", end: "B3:port1", k: 3,
+			restricted: []string{"A2:port1", "B1:port1"},
+		},
+	}
+	for _, c := range cases {
+		g := testGraph(c.ports, c.links)
+		restricted := make(map[string]bool)
+		for _, v := range c.restricted {
+			restricted[v] = true
+		}
+		paths := g.KShortestPaths(c.start, c.end, c.k, restricted, c.weight)
+		checkPortPaths(t, g, paths)
+
+		// Paths of equal cost may come in any order
+		got := nodePaths(g, paths)
+		order := make([]int, len(got))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(x, y int) bool {
+			i, j := order[x], order[y]
+			return paths[i].cost < paths[j].cost || paths[i].cost == paths[j].cost && got[i] < got[j]
+		})
+		var sorted []string
+		for _, i := range order {
+			sorted = append(sorted, got[i])
+		}
+		got = sorted
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		for i := 1; i < len(paths); i++ {
+			if paths[i].cost < paths[i-1].cost {
+				t.Errorf("%s: path %d costs %v, less than the one before", c.name, i, paths[i].cost)
+			}
+		}
+	}
+}
+
+func TestKShortestPathsOnMergedPorts(t *testing.T) {
+	g := testGraph(map[string]int{"S": 2, "M": 3, "T": 1}, []testLink{{"S", "M", 1}, {"M", "T", 1}})
+
+	paths := g.KShortestPaths("S:port1", "T:port1", 3, nil, "")
+	if len(paths) != 1 || !reflect.DeepEqual(paths[0].Path, []string{"S:port1", "M:port1", "T:port1"}) {
+		t.Fatalf("got %v, want S:port1 M:port1 T:port1", paths)
+	}
+
+	// A restricted port is avoided, not its node
+	paths = g.KShortestPaths("S:port1", "T:port1", 3, map[string]bool{"M:port1": true}, "")
+	if len(paths) != 1 || paths[0].Path[1] != "M:port2" {
+		t.Fatalf("got %v, want a path through M:port2", paths)
+	}
+
+	restricted := map[string]bool{"M:port1": true, "M:port2": true, "M:port3": true}
+	if paths := g.KShortestPaths("S:port1", "T:port1", 3, restricted, ""); len(paths) != 0 {
+		t.Fatalf("got %v with every port of M restricted, want none", paths)
+	}
+}
+
+func TestDisjointPaths(t *testing.T) {
+	cases := []struct {
+		name         string
+		ports        map[string]int
+		links        []testLink
+		k            int
+		nodeDisjoint bool
+		want         []string
+	}{
+		{name: "trap, link-disjoint", links: trap, k: 2, want: []string{"S-A-D-T", "S-C-B-T"}},
+		{name: "trap, node-disjoint", links: trap, k: 2, nodeDisjoint: true, want: []string{"S-A-D-T", "S-C-B-T"}},
+		{name: "trap, more than exist", links: trap, k: 3, want: []string{"S-A-D-T", "S-C-B-T"}},
+		{name: "trap, one path", links: trap, k: 1, want: []string{"S-A-B-T"}},
+		{name: "bowtie, link-disjoint", links: bowtie, k: 2, want: []string{"S-M-T", "S-A-M-B-T"}},
+		{name: "bowtie, node-disjoint", links: bowtie, k: 2, nodeDisjoint: true, want: []string{"S-M-T"}},
+		{
+			// Three ports at M would allow three port-disjoint paths
+			name: "multi-port node, node-disjoint", ports: map[string]int{"M": 3},
+			links: []testLink{{"S", "M", 1}, {"M", "T", 1}}, k: 3, nodeDisjoint: true, want: []string{"S-M-T"},
+		},
+		{
+			// Zero-latency links leave ties for the flow to split
+			name: "zero latency", links: []testLink{{"S", "A", 0}, {"A", "T", 0}, {"S", "B", 0}, {"B", "T", 0}, {"A", "B", 0}},
+			k: 3, want: []string{"S-A-T", "S-B-T"},
+		},
+		{
+			name: "parallel ports are one link", ports: map[string]int{"S": 2, "T": 2},
+			links: []testLink{{"S", "T", 1}}, k: 3, want: []string{"S-T"},
+		},
+	}
+	for _, c := range cases {
+		g := testGraph(c.ports, c.links)
+		paths := g.DisjointPaths("S:port1", "T:port1", c.k, c.nodeDisjoint, nil, WeightLatency)
+		checkPortPaths(t, g, paths)
+
+		// Disjoint paths are a set; compare them in sorted order
+		got := nodePaths(g, paths)
+		sort.Strings(got)
+		want := append([]string{}, c.want...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+	}
+}
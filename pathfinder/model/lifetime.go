@@ -0,0 +1,62 @@
+package model
+
+import "math"
+
+// timeEpsilon absorbs rounding when comparing sim times.
+const timeEpsilon = 1e-6
+
+// Lifetime predicts how long a path stays usable: until the first of its links
+// leaves its current contact. AtLeast is set when no link breaks within the
+// contact plan, so the path lasts at least until ValidUntilS.
+type Lifetime struct {
+	LifetimeS    float64  `json:"lifetime_s"`
+	ValidUntilS  float64  `json:"valid_until_s"`
+	AtLeast      bool     `json:"at_least"`
+	BreakingLink []string `json:"breaking_link,omitempty"`
+}
+
+// Lifetime predicts the remaining lifetime of a path of g from the plan. A
+// link with no contact open at the graph's sim time is taken to break now.
+// When the plan starts after the graph, its contacts open at the plan's start
+// are taken to be open since the graph's sim time; the plan cannot tell
+// otherwise.
+func (p *ContactPlan) Lifetime(g *Graph, path []string) Lifetime {
+	now := g.SimTime()
+	at := math.Max(now, p.SimTimeS)
+	horizonEnd := p.SimTimeS + p.HorizonS
+	until := horizonEnd
+	var breaking []string
+
+	for i := 1; i < len(path); i++ {
+		a, b := g.node(path[i-1]), g.node(path[i])
+		if a == b {
+			continue
+		}
+		end, open := p.contactEnd(a, b, at)
+		if !open {
+			end = now
+		}
+		if end < until && end < horizonEnd-timeEpsilon {
+			until, breaking = end, []string{a, b}
+		}
+	}
+
+	return Lifetime{
+		LifetimeS:    math.Max(until-now, 0),
+		ValidUntilS:  math.Max(until, now),
+		AtLeast:      breaking == nil,
+		BreakingLink: breaking,
+	}
+}
+
+// contactEnd returns the end of the contact between a and b open at the given
+// sim time, and false when there is none.
+func (p *ContactPlan) contactEnd(a, b string, at float64) (float64, bool) {
+	for _, ci := range p.byNode[a] {
+		c := p.Contacts[ci]
+		if (c.A == b || c.B == b) && c.StartS <= at+timeEpsilon && c.EndS >= at-timeEpsilon {
+			return c.EndS, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+// lagGraph is a graph at sim time 100 s with the path a - b - c, a step or
+// so behind a plan fetched at 130 s.
+func lagGraph() *Graph {
+	g := NewGraph()
+	g.simTime = 100
+	for _, id := range []string{"a", "b", "c"} {
+		g.nodeOf[id+":port1"] = id
+		g.portgen[id+":port1"] = 1
+	}
+	g.AddEdges("b:port1", []string{"a:port1", "c:port1"})
+	return g
+}
+
+func lagPlan() *ContactPlan {
+	p := &ContactPlan{SimTimeS: 130, HorizonS: 3600}
+	p.AddContact(Contact{A: "a", B: "b", StartS: 130, EndS: 500})
+	p.AddContact(Contact{A: "c", B: "b", StartS: 130, EndS: 900})
+	return p
+}
+
+func TestLifetimeWithPlanNewerThanGraph(t *testing.T) {
+	g, p := lagGraph(), lagPlan()
+
+	l := p.Lifetime(g, []string{"a:port1", "b:port1", "c:port1"})
+	if math.Abs(l.LifetimeS-400) > timeEpsilon || math.Abs(l.ValidUntilS-500) > timeEpsilon {
+		t.Errorf("lifetime %v s until %v s, want 400 s until 500 s", l.LifetimeS, l.ValidUntilS)
+	}
+	if l.AtLeast || len(l.BreakingLink) != 2 || l.BreakingLink[0] != "a" || l.BreakingLink[1] != "b" {
+		t.Errorf("breaking link %v (at least %v), want [a b]", l.BreakingLink, l.AtLeast)
+	}
+
+	// A link without a contact at the plan's start breaks now
+	g.nodeOf["d:port1"] = "d"
+	l = p.Lifetime(g, []string{"a:port1", "d:port1"})
+	if l.LifetimeS != 0 || l.ValidUntilS != 100 {
+		t.Errorf("lifetime %v s until %v s, want 0 s until 100 s", l.LifetimeS, l.ValidUntilS)
+	}
+}
+
+func TestContactPlanReusedWhenNewerThanGraph(t *testing.T) {
+	c := NewGraphCache()
+	p := lagPlan()
+	c.plan = p
+
+	// A fetch needs a simulator, so getting the cached plan back means no refetch
+	got, err := c.ContactPlan(lagGraph())
+	if err != nil || got != p {
+		t.Fatalf("got plan %p (%v), want the cached plan %p", got, err, p)
+	}
+	if p.covers(130 - 3600) {
+		t.Error("plan at 130 s covers a graph a full horizon older, as after a simulator restart")
+	}
+}
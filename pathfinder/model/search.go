@@ -0,0 +1,130 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+)
+
+// queueItem is a vertex waiting in the search queue with its priority; lower
+// priorities are popped first.
+type queueItem struct {
+	node     string
+	priority float64
+}
+
+// pathQueue is a binary min-heap of vertices for best-first searches.
+type pathQueue []queueItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// WidestPath returns the path whose narrowest edge has the highest capacity.
+// Restricted vertices are avoided unless they are the start or the end, and
+// so are vertices with a portgen of zero or less, which cannot carry traffic.
+func (g *Graph) WidestPath(start, end string, restricted map[string]bool) ([]string, bool) {
+	// The heap pops the lowest priority, so widths are negated
+	return g.bestFirst(start, end, restricted, float64(-g.portgen[start]), func(width float64, u, v string) float64 {
+		capacity := g.Edge(u, v).Capacity
+		if capacity <= 0 || width >= 0 {
+			return math.Inf(1)
+		}
+		return math.Max(width, float64(-capacity))
+	}, nil)
+}
+
+// ShortestLatencyPath returns the path with the lowest total propagation latency.
+func (g *Graph) ShortestLatencyPath(start, end string, restricted map[string]bool) ([]string, bool) {
+	return g.bestFirst(start, end, restricted, 0, g.addLatency, nil)
+}
+
+// MinHopPath returns a path with the fewest edges.
+func (g *Graph) MinHopPath(start, end string, restricted map[string]bool) ([]string, bool) {
+	return g.bestFirst(start, end, restricted, 0, func(hops float64, _, _ string) float64 {
+		return hops + 1
+	}, nil)
+}
+
+// AStarPath returns the lowest-latency path like ShortestLatencyPath, guided
+// towards the end by the straight-line latency, which never overestimates.
+func (g *Graph) AStarPath(start, end string, restricted map[string]bool) ([]string, bool) {
+	target := g.position[g.node(end)]
+	return g.bestFirst(start, end, restricted, 0, g.addLatency, func(v string) float64 {
+		p := g.position[g.node(v)]
+		return math.Hypot(target[0]-p[0], target[1]-p[1]) / SpeedOfLightKmS * 1000
+	})
+}
+
+func (g *Graph) addLatency(latency float64, u, v string) float64 {
+	return latency + g.Edge(u, v).LatencyMs
+}
+
+// bestFirst is a label-setting search: extend gives the cost of reaching v
+// through u, or +Inf when the edge cannot be used, and must never decrease
+// along a path. An optional heuristic
+// estimates the remaining cost to the end; it must be consistent.
+func (g *Graph) bestFirst(start, end string, restricted map[string]bool, initial float64, extend func(cost float64, u, v string) float64, heuristic func(v string) float64) ([]string, bool) {
+	if start == end {
+		return []string{start}, true
+	}
+
+	estimate := func(v string, cost float64) float64 {
+		if heuristic == nil {
+			return cost
+		}
+		return cost + heuristic(v)
+	}
+
+	cost := map[string]float64{start: initial}
+	prev := map[string]string{}
+	visited := map[string]bool{}
+	queue := &pathQueue{{node: start, priority: estimate(start, initial)}}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queueItem)
+		if visited[current.node] {
+			continue
+		}
+		visited[current.node] = true
+
+		if current.node == end {
+			return buildPath(prev, start, end), true
+		}
+
+		for _, neighbor := range g.adj[current.node] {
+			if neighbor != start && neighbor != end && restricted[neighbor] {
+				continue
+			}
+			if visited[neighbor] {
+				continue
+			}
+
+			next := extend(cost[current.node], current.node, neighbor)
+			if math.IsInf(next, 1) {
+				continue
+			}
+			if best, seen := cost[neighbor]; !seen || next < best {
+				cost[neighbor] = next
+				prev[neighbor] = current.node
+				heap.Push(queue, queueItem{node: neighbor, priority: estimate(neighbor, next)})
+			}
+		}
+	}
+	return nil, false
+}
+
+func buildPath(prev map[string]string, start, end string) []string {
+	path := []string{end}
+	for node := end; node != start; {
+		node = prev[node]
+		path = append([]string{node}, path...)
+	}
+	return path
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Strategy is a routing algorithm run on a graph. Restricted vertices must be
+// avoided unless they are the start or the end.
+type Strategy interface {
+	Name() string
+	Path(g *Graph, start, end string, restricted map[string]bool) ([]string, bool)
+}
+
+// Objective is implemented by strategies that can score any path by what they
+// optimize, so alternatives can be compared. Lower costs are better.
+type Objective interface {
+	Cost(g *Graph, path []string) float64
+	Describe(cost float64) string
+}
+
+// DefaultStrategy is used when a request does not name one.
+const DefaultStrategy = "widest"
+
+var strategies = make(map[string]Strategy)
+
+// Every available strategy is registered here
+func init() {
+	RegisterStrategy(strategyFunc{"widest", (*Graph).WidestPath, widest})
+	RegisterStrategy(strategyFunc{"min-hop", (*Graph).MinHopPath, hops})
+	RegisterStrategy(strategyFunc{"min-latency", (*Graph).ShortestLatencyPath, latency})
+	RegisterStrategy(strategyFunc{"astar", (*Graph).AStarPath, latency})
+}
+
+// RegisterStrategy makes a strategy available by its name, replacing any
+// strategy registered under the same name.
+func RegisterStrategy(s Strategy) {
+	strategies[s.Name()] = s
+}
+
+// LookupStrategy returns the strategy registered under name, or the default
+// strategy for an empty name.
+func LookupStrategy(name string) (Strategy, bool) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	s, ok := strategies[name]
+	return s, ok
+}
+
+// StrategyNames lists the registered strategies, sorted.
+func StrategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// strategyFunc adapts a Graph search method and its objective to the
+// Strategy and Objective interfaces.
+type strategyFunc struct {
+	name      string
+	search    func(g *Graph, start, end string, restricted map[string]bool) ([]string, bool)
+	objective objective
+}
+
+func (s strategyFunc) Name() string { return s.name }
+
+func (s strategyFunc) Path(g *Graph, start, end string, restricted map[string]bool) ([]string, bool) {
+	return s.search(g, start, end, restricted)
+}
+
+func (s strategyFunc) Cost(g *Graph, path []string) float64 { return s.objective.cost(g, path) }
+
+func (s strategyFunc) Describe(cost float64) string { return s.objective.describe(cost) }
+
+type objective struct {
+	cost     func(g *Graph, path []string) float64
+	describe func(cost float64) string
+}
+
+var (
+	widest = objective{
+		cost: func(g *Graph, path []string) float64 {
+			return float64(-g.Bottleneck(path))
+		},
+		describe: func(cost float64) string { return fmt.Sprintf("bottleneck capacity %d", int(-cost)) },
+	}
+	hops = objective{
+		cost:     func(_ *Graph, path []string) float64 { return float64(len(path) - 1) },
+		describe: func(cost float64) string { return fmt.Sprintf("%d hops", int(cost)) },
+	}
+	latency = objective{
+		cost:     func(g *Graph, path []string) float64 { return g.Latency(path) },
+		describe: func(cost float64) string { return fmt.Sprintf("latency %.3f ms", cost) },
+	}
+)
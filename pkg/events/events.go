@@ -0,0 +1,17 @@
+package events
+
+// LinkRef identifies an undirected link between two nodes, with A < B.
+type LinkRef struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// StepEvent is published after every simulation step. Added and Removed hold
+// the usable links that appeared and disappeared since the previous step, so
+// consumers can keep a local topology without refetching it.
+type StepEvent struct {
+	Step     int       `json:"step"`
+	SimTimeS float64   `json:"sim_time_s"`
+	Added    []LinkRef `json:"added"`
+	Removed  []LinkRef `json:"removed"`
+}
@@ -0,0 +1,207 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// StepStream is the Redis stream simulation steps are appended to.
+	StepStream = "simulation.steps"
+
+	// RetryInterval is how long an entry must stay pending before it is
+	// claimed from another consumer of the group, and how often a consumer
+	// looks for such entries.
+	RetryInterval = 30 * time.Second
+
+	// handleRetry is how long a consumer waits before retrying an entry it
+	// failed to handle.
+	handleRetry = time.Second
+)
+
+// StreamRetention is how long entries stay in the stream; older ones are
+// trimmed approximately as new steps are published. Entry IDs are publish
+// times, so the retention holds in time whatever the step rate.
+var StreamRetention = 10 * time.Minute
+
+// PublishStep appends a step event to the step stream.
+func PublishStep(ctx context.Context, rdb *redis.Client, event StepEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: StepStream,
+		MinID:  fmt.Sprintf("%d-0", time.Now().Add(-StreamRetention).UnixMilli()),
+		Approx: true,
+		Values: map[string]interface{}{"event": payload},
+	}).Err()
+}
+
+// Consumer reads the step stream as a member of a consumer group. Entries are
+// acknowledged once handled, so a restarted consumer with the same name first
+// replays what it had received but not acknowledged, then resumes after the
+// group's last delivered entry. An entry whose handling failed is retried
+// until it succeeds, and no later entry is handled or acknowledged before it,
+// so steps are applied in order. Every RetryInterval the consumer also claims
+// the entries other consumers of the group, such as ones that stopped, left
+// pending that long; those are older than what it already handled.
+type Consumer struct {
+	rdb   *redis.Client
+	group string
+	name  string
+
+	mu       sync.Mutex
+	lastStep int
+}
+
+// Lag reports how far a consumer group is behind the stream.
+type Lag struct {
+	Group    string `json:"group"`
+	Consumer string `json:"consumer"`
+	LastStep int    `json:"last_step"`
+	Pending  int64  `json:"pending"`
+	Lag      int64  `json:"lag"`
+}
+
+// NewConsumer joins the group, creating it (and the stream) when missing. A
+// new group starts with the entries published after its creation.
+func NewConsumer(ctx context.Context, rdb *redis.Client, group, name string) (*Consumer, error) {
+	err := rdb.XGroupCreateMkStream(ctx, StepStream, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+	return &Consumer{rdb: rdb, group: group, name: name}, nil
+}
+
+// Run hands every step event to handle, in stream order, until ctx is done.
+// Entries are acknowledged only when handle returns nil; on an error Run
+// stops at that entry and replays from it after handleRetry.
+func (c *Consumer) Run(ctx context.Context, handle func(StepEvent) error) error {
+	// An explicit ID replays this consumer's pending entries after it, once;
+	// ">" then reads new ones
+	id := "0"
+	retry := time.Now().Add(RetryInterval)
+	for ctx.Err() == nil {
+		if id == ">" && time.Now().After(retry) {
+			retry = time.Now().Add(RetryInterval)
+			c.claimStale(ctx)
+			id = "0"
+		}
+		streams, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.name,
+			Streams:  []string{StepStream, id},
+			Count:    100,
+			Block:    5 * time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("⚠️ Failed to read %s: %v", StepStream, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		messages, failed := 0, false
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				if failed {
+					break
+				}
+				messages++
+				if id != ">" {
+					id = msg.ID
+				}
+				var event StepEvent
+				payload, _ := msg.Values["event"].(string)
+				if err := json.Unmarshal([]byte(payload), &event); err != nil {
+					// Malformed entries would be replayed forever, drop them
+					log.Printf("⚠️ Invalid step event %s: %v", msg.ID, err)
+					c.rdb.XAck(ctx, StepStream, c.group, msg.ID)
+					continue
+				}
+				if err := handle(event); err != nil {
+					// Later entries stay pending behind it and are replayed in order
+					log.Printf("⚠️ Failed to handle step %d, retrying: %v", event.Step, err)
+					failed = true
+					continue
+				}
+				if err := c.rdb.XAck(ctx, StepStream, c.group, msg.ID).Err(); err != nil {
+					log.Printf("⚠️ Failed to acknowledge step %d: %v", event.Step, err)
+				}
+				c.mu.Lock()
+				c.lastStep = event.Step
+				c.mu.Unlock()
+			}
+		}
+		if failed {
+			select {
+			case <-ctx.Done():
+			case <-time.After(handleRetry):
+			}
+			id = "0"
+			continue
+		}
+		if messages == 0 {
+			id = ">"
+		}
+	}
+	return ctx.Err()
+}
+
+// claimStale takes over the entries other consumers of the group have left
+// pending for longer than RetryInterval, so the next replay retries them.
+func (c *Consumer) claimStale(ctx context.Context) {
+	start := "0-0"
+	for {
+		_, next, err := c.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   StepStream,
+			Group:    c.group,
+			Consumer: c.name,
+			MinIdle:  RetryInterval,
+			Start:    start,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			log.Printf("⚠️ Failed to claim stale entries of %s: %v", StepStream, err)
+			return
+		}
+		if next == "0-0" {
+			return
+		}
+		start = next
+	}
+}
+
+// Lag returns the group's pending and undelivered entry counts.
+func (c *Consumer) Lag(ctx context.Context) (Lag, error) {
+	c.mu.Lock()
+	lag := Lag{Group: c.group, Consumer: c.name, LastStep: c.lastStep}
+	c.mu.Unlock()
+
+	groups, err := c.rdb.XInfoGroups(ctx, StepStream).Result()
+	if err != nil {
+		return lag, err
+	}
+	for _, g := range groups {
+		if g.Name == c.group {
+			lag.Pending = g.Pending
+			lag.Lag = g.Lag
+			return lag, nil
+		}
+	}
+	return lag, fmt.Errorf("consumer group %s not found", c.group)
+}
@@ -0,0 +1,174 @@
+// Package visibility encodes the simulator's visibility between nodes in the
+// formats clients can negotiate with the Accept header: the dense JSON matrix,
+// a sparse JSON adjacency list and a compact binary edge list. The sparse
+// formats name nodes by ID, so they do not depend on the order of /positions.
+package visibility
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Media types of the visibility formats.
+const (
+	MediaMatrix    = "application/json"
+	MediaAdjacency = "application/vnd.orbital.adjacency+json"
+	MediaBinary    = "application/vnd.orbital.adjacency"
+)
+
+// magic opens every binary encoding, followed by a version byte.
+const (
+	magic   = "OVIS"
+	version = 1
+)
+
+// Adjacency lists the visible neighbours of every node by ID. Nodes without
+// neighbours are present with an empty list.
+type Adjacency struct {
+	Step      int                 `json:"step"`
+	Adjacency map[string][]string `json:"adjacency"`
+}
+
+// Sparse converts a matrix indexed like ids into an adjacency list.
+func Sparse(step int, ids []string, matrix [][]bool) Adjacency {
+	adj := Adjacency{Step: step, Adjacency: make(map[string][]string, len(ids))}
+	for i, id := range ids {
+		neighbors := []string{}
+		for j, visible := range matrix[i] {
+			if visible && i != j {
+				neighbors = append(neighbors, ids[j])
+			}
+		}
+		adj.Adjacency[id] = neighbors
+	}
+	return adj
+}
+
+// Negotiate picks the format for an Accept header: the supported type with
+// the highest quality value, the first listed on a tie. Types with q=0 are
+// refused; without any acceptable supported type it defaults to the matrix.
+func Negotiate(accept string) string {
+	best, bestQ := MediaMatrix, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch media {
+		case MediaAdjacency, MediaBinary, MediaMatrix:
+		default:
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = media, q
+		}
+	}
+	return best
+}
+
+// MarshalBinary encodes the visibility as, little-endian:
+//
+//	"OVIS" version:uint8 step:uint32
+//	nodes:uint32 { len:uint16 id:[len]byte }
+//	edges:uint32 { a:uint32 b:uint32 }
+//
+// Edges are undirected, listed once with a < b, and index the node table.
+// Steps must not be negative.
+func MarshalBinary(step int, ids []string, matrix [][]bool) ([]byte, error) {
+	if step < 0 || int64(step) > math.MaxUint32 {
+		return nil, fmt.Errorf("step %d cannot be encoded, the binary format needs a non-negative step", step)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.WriteByte(version)
+	binary.Write(&buf, binary.LittleEndian, uint32(step))
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(ids)))
+	for _, id := range ids {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(id)))
+		buf.WriteString(id)
+	}
+
+	var edges []uint32
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			if matrix[i][j] || matrix[j][i] {
+				edges = append(edges, uint32(i), uint32(j))
+			}
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(len(edges)/2))
+	binary.Write(&buf, binary.LittleEndian, edges)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a binary encoding into an adjacency list.
+func UnmarshalBinary(data []byte) (Adjacency, error) {
+	r := bytes.NewReader(data)
+	head := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r, head); err != nil || string(head[:len(magic)]) != magic {
+		return Adjacency{}, errors.New("not a visibility encoding")
+	}
+	if head[len(magic)] != version {
+		return Adjacency{}, fmt.Errorf("unsupported visibility encoding version %d", head[len(magic)])
+	}
+
+	var step, count uint32
+	if err := binary.Read(r, binary.LittleEndian, &step); err != nil {
+		return Adjacency{}, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return Adjacency{}, err
+	}
+	// Every node takes at least its length prefix; check before allocating
+	if int64(count)*2 > int64(r.Len()) {
+		return Adjacency{}, fmt.Errorf("%d nodes do not fit in %d remaining bytes", count, r.Len())
+	}
+	ids := make([]string, 0, count)
+	adj := Adjacency{Step: int(step), Adjacency: make(map[string][]string, count)}
+	for i := uint32(0); i < count; i++ {
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return Adjacency{}, err
+		}
+		id := make([]byte, n)
+		if _, err := io.ReadFull(r, id); err != nil {
+			return Adjacency{}, err
+		}
+		ids = append(ids, string(id))
+		adj.Adjacency[string(id)] = []string{}
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return Adjacency{}, err
+	}
+	if int64(count)*8 != int64(r.Len()) {
+		return Adjacency{}, fmt.Errorf("%d edges do not match %d remaining bytes", count, r.Len())
+	}
+	edges := make([]uint32, 2*count)
+	if err := binary.Read(r, binary.LittleEndian, edges); err != nil {
+		return Adjacency{}, err
+	}
+	for e := 0; e < len(edges); e += 2 {
+		a, b := edges[e], edges[e+1]
+		if int(a) >= len(ids) || int(b) >= len(ids) {
+			return Adjacency{}, fmt.Errorf("edge %d references unknown node", e/2)
+		}
+		adj.Adjacency[ids[a]] = append(adj.Adjacency[ids[a]], ids[b])
+		adj.Adjacency[ids[b]] = append(adj.Adjacency[ids[b]], ids[a])
+	}
+	return adj, nil
+}
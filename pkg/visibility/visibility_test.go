@@ -0,0 +1,109 @@
+package visibility
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var (
+	testIDs    = []string{"sat_a", "sat_b", "srv_c", "sat_d"}
+	testMatrix = [][]bool{
+		{true, true, false, true},
+		{true, true, true, false},
+		{false, true, true, false},
+		{true, false, false, true},
+	}
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	data, err := MarshalBinary(42, testIDs, testMatrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, neighbors := range got.Adjacency {
+		sort.Strings(neighbors)
+	}
+	if want := Sparse(42, testIDs, testMatrix); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave %+v, want %+v", got, want)
+	}
+}
+
+func TestMarshalBinaryRejectsSteps(t *testing.T) {
+	for _, step := range []int{-1, 1 << 32} {
+		if _, err := MarshalBinary(step, testIDs, testMatrix); err == nil {
+			t.Errorf("step %d encoded without error", step)
+		}
+	}
+}
+
+func TestUnmarshalBinaryRejectsCorruptInput(t *testing.T) {
+	valid, err := MarshalBinary(7, testIDs, testMatrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Offsets into the encoding: magic and version, step, node count
+	const nodeCount = len(magic) + 1 + 4
+	edgeCount := nodeCount + 4
+	for _, id := range testIDs {
+		edgeCount += 2 + len(id)
+	}
+
+	corrupt := func(edit func([]byte)) []byte {
+		data := append([]byte{}, valid...)
+		edit(data)
+		return data
+	}
+	cases := map[string][]byte{
+		"empty":       nil,
+		"bad magic":   corrupt(func(d []byte) { d[0] = 'X' }),
+		"bad version": corrupt(func(d []byte) { d[len(magic)] = version + 1 }),
+		"no step":     valid[:len(magic)+1],
+		"huge node count": corrupt(func(d []byte) {
+			binary.LittleEndian.PutUint32(d[nodeCount:], 1<<31)
+		}),
+		"truncated node id": valid[:edgeCount-1],
+		"huge edge count": corrupt(func(d []byte) {
+			binary.LittleEndian.PutUint32(d[edgeCount:], 1<<31)
+		}),
+		"truncated edges": valid[:len(valid)-1],
+		"trailing bytes":  append(append([]byte{}, valid...), 0),
+		"unknown node": corrupt(func(d []byte) {
+			binary.LittleEndian.PutUint32(d[edgeCount+4:], uint32(len(testIDs)))
+		}),
+	}
+	for name, data := range cases {
+		if _, err := UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct{ accept, want string }{
+		{"", MediaMatrix},
+		{"*/*", MediaMatrix},
+		{"text/html", MediaMatrix},
+		{MediaBinary, MediaBinary},
+		{MediaAdjacency, MediaAdjacency},
+		{"text/html, " + MediaBinary, MediaBinary},
+		{MediaAdjacency + ", " + MediaBinary, MediaAdjacency},
+		{MediaAdjacency + ";q=0.5, " + MediaBinary, MediaBinary},
+		{MediaBinary + ";q=0.2, " + MediaAdjacency + ";q=0.9", MediaAdjacency},
+		{MediaBinary + ";q=0.4, application/json;q=0.3", MediaBinary},
+		{MediaBinary + ";q=0", MediaMatrix},
+		{MediaBinary + ";q=0, " + MediaAdjacency + ";q=0.1", MediaAdjacency},
+		{MediaBinary + ";q=2, " + MediaAdjacency + ";q=abc", MediaMatrix},
+		{"application/vnd.orbital.unknown, " + MediaBinary + ";q=0.1", MediaBinary},
+	}
+	for _, c := range cases {
+		if got := Negotiate(c.accept); got != c.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", c.accept, got, c.want)
+		}
+	}
+}
@@ -1,55 +1,571 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"satellite-coms/pkg/events"
+	"satellite-coms/pkg/visibility"
+	"satellite-coms/simulator/model"
+	"satellite-coms/simulator/shard"
 	"satellite-coms/simulator/simulation"
 )
 
+// GetPositionsHandler returns the node positions at the current step, or at
+// the sim time given by ?t= (seconds, past or future) without advancing the simulation.
 func GetPositionsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+
 	simulation.Mutex.Lock()
-	defer simulation.Mutex.Unlock()
+	step, requested, err := requestedStep(r)
+	if err != nil {
+		simulation.Mutex.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setStepHeaders(w, step)
 
-	positions := make([]map[string]interface{}, len(simulation.Nodes))
-	for i, node := range simulation.Nodes {
-		x, y := node.Position()
-		positions[i] = map[string]interface{}{
-			"id":      node.ID,
-			"name":    node.Name,
-			"x":       x,
-			"y":       y,
-			"ports":   node.Ports,
-			"portgen": node.PortGen,
+	if shard.Enabled() {
+		simulation.Mutex.Unlock()
+		states, err := shard.Nodes(r.Context(), step)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
 		}
+		json.NewEncoder(w).Encode(positions(shard.ToNodes(states)))
+		return
 	}
-	json.NewEncoder(w).Encode(positions)
+
+	defer simulation.Mutex.Unlock()
+	if requested {
+		json.NewEncoder(w).Encode(positions(simulation.PropagateNodes(simulation.Nodes, step-simulation.StepCount)))
+		return
+	}
+	json.NewEncoder(w).Encode(positions(simulation.Nodes))
 }
 
+// GetVisibilityMatrixHandler returns the usable links at the current step. With
+// ?t= it returns the predicted links at that sim time instead; predictions
+// cover line of sight and antennas but not terminal acquisition or interference.
 func GetVisibilityMatrixHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	simulation.Mutex.Lock()
+	step, requested, err := requestedStep(r)
+	if err != nil {
+		simulation.Mutex.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	setStepHeaders(w, step)
+
+	if shard.Enabled() {
+		simulation.Mutex.Unlock()
+		states, matrix, err := shard.VisibilityMatrix(r.Context(), step)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeVisibility(w, r, step, shard.ToNodes(states), matrix)
+		return
+	}
+
+	if !requested {
+		defer simulation.Mutex.Unlock()
+		writeVisibility(w, r, step, simulation.Nodes, simulation.Links.Matrix())
+		return
+	}
+	nodes := simulation.PropagateNodes(simulation.Nodes, step-simulation.StepCount)
+	simulation.Mutex.Unlock()
+
+	writeVisibility(w, r, step, nodes, simulation.VisibilityMatrix(nodes))
+}
+
+// writeVisibility encodes the matrix in the format the Accept header asks for:
+// the dense matrix by default, or a sparse adjacency list keyed by node ID as
+// JSON or binary.
+func writeVisibility(w http.ResponseWriter, r *http.Request, step int, nodes []*model.Node, matrix [][]bool) {
+	w.Header().Set("Vary", "Accept")
+	format := visibility.Negotiate(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", format)
+	switch format {
+	case visibility.MediaBinary:
+		data, err := visibility.MarshalBinary(step, nodeIDs(nodes), matrix)
+		if err != nil {
+			w.Header().Del("Content-Type")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(data)
+	case visibility.MediaAdjacency:
+		json.NewEncoder(w).Encode(visibility.Sparse(step, nodeIDs(nodes), matrix))
+	default:
+		json.NewEncoder(w).Encode(matrix)
+	}
+}
+
+// snapshotVisibility is the visibility field of a snapshot: the matrix, or the
+// adjacency list when the client accepts the sparse JSON format.
+func snapshotVisibility(r *http.Request, nodes []*model.Node, matrix [][]bool) interface{} {
+	if visibility.Negotiate(r.Header.Get("Accept")) == visibility.MediaAdjacency {
+		return visibility.Sparse(0, nodeIDs(nodes), matrix).Adjacency
+	}
+	return matrix
+}
+
+func nodeIDs(nodes []*model.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
+
+// GetSnapshotHandler returns nodes, visibility and links for a single step,
+// taken under one lock so they are always consistent. The step is the ETag;
+// a request with a matching If-None-Match, or with ?since= equal to the
+// current step, gets 304 Not Modified. Clients accepting the sparse adjacency
+// format get visibility as an adjacency list keyed by node ID.
+func GetSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	since := -1
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "since must be a step number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var snapshot map[string]interface{}
+	if shard.Enabled() {
+		simulation.Mutex.Lock()
+		step := simulation.StepCount
+		simulation.Mutex.Unlock()
+
+		if notModified(w, r, step, since) {
+			return
+		}
+		states, matrix, err := shard.VisibilityMatrix(r.Context(), step)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		nodes := shard.ToNodes(states)
+		snapshot = map[string]interface{}{
+			"step":       step,
+			"sim_time_s": simulation.Scale.SimTime(step),
+			"units":      simulation.Scale,
+			"nodes":      positions(nodes),
+			"visibility": snapshotVisibility(r, nodes, matrix),
+			"links":      simulation.StatelessLinks(nodes, matrix, simulation.Scale),
+		}
+	} else {
+		simulation.Mutex.Lock()
+		step := simulation.StepCount
+		if notModified(w, r, step, since) {
+			simulation.Mutex.Unlock()
+			return
+		}
+		snapshot = map[string]interface{}{
+			"step":       step,
+			"sim_time_s": simulation.Scale.SimTime(step),
+			"units":      simulation.Scale,
+			"nodes":      positions(simulation.Nodes),
+			"visibility": snapshotVisibility(r, simulation.Nodes, simulation.Links.Matrix()),
+			"links":      simulation.Links.Links(),
+		}
+		simulation.Mutex.Unlock()
+	}
+
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// notModified sets the snapshot ETag and answers 304 when the client already
+// holds this step.
+func notModified(w http.ResponseWriter, r *http.Request, step, since int) bool {
+	etag := fmt.Sprintf(`"step-%d"`, step)
+	w.Header().Set("ETag", etag)
+	setStepHeaders(w, step)
+	if r.Header.Get("If-None-Match") == etag || since == step {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// GetUnitsHandler describes the units of the simulation outputs: positions are
+// in planet radii, velocities in planet radii per step, and km_per_unit and
+// seconds_per_step convert them to physical units.
+func GetUnitsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
 	simulation.Mutex.Lock()
 	defer simulation.Mutex.Unlock()
 
-	matrix := make([][]bool, len(simulation.Nodes))
-	for i := range matrix {
-		matrix[i] = make([]bool, len(simulation.Nodes))
-		for j := range matrix[i] {
-			if i != j {
-				matrix[i][j] = simulation.Nodes[i].CanView(simulation.Nodes[j])
-			}
+	json.NewEncoder(w).Encode(simulation.Scale)
+}
+
+// GetLinksHandler returns every line-of-sight pair with its terminal and acquisition state.
+func GetLinksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if shard.Enabled() {
+		_, links, err := shardLinks(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
 		}
+		json.NewEncoder(w).Encode(links)
+		return
+	}
+
+	simulation.Mutex.Lock()
+	defer simulation.Mutex.Unlock()
+	json.NewEncoder(w).Encode(simulation.Links.Links())
+}
+
+// shardLinks gathers every node and the full visibility matrix at the current
+// step from all shards and derives the links from them. Sharded scenarios have
+// no stateful link features, so these are the links the designated shard tracks.
+func shardLinks(r *http.Request) ([]*model.Node, []simulation.Link, error) {
+	simulation.Mutex.Lock()
+	step := simulation.StepCount
+	simulation.Mutex.Unlock()
+
+	states, matrix, err := shard.VisibilityMatrix(r.Context(), step)
+	if err != nil {
+		return nil, nil, err
 	}
-	json.NewEncoder(w).Encode(matrix)
+	nodes := shard.ToNodes(states)
+	return nodes, simulation.StatelessLinks(nodes, matrix, simulation.Scale), nil
 }
 
 func StepHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	StepSimulation()
+	w.Write([]byte("OK"))
+}
+
+// stepMu serializes StepSimulation. A sharded step releases the simulation
+// lock while it advances and gathers the other shards, so without it the
+// step loop and POST /step could interleave and apply link updates out of
+// order.
+var stepMu sync.Mutex
+
+// StepSimulation advances the simulation one step and returns the step event
+// describing it. When the simulation is sharded it is only called on the
+// designated shard, which moves the other shards along and tracks the links
+// from the gathered visibility; if that fails the event carries no link
+// changes, and the next one reports them.
+func StepSimulation() events.StepEvent {
+	stepMu.Lock()
+	defer stepMu.Unlock()
+
+	simulation.Mutex.Lock()
+	simulation.Step(simulation.Nodes)
+	simulation.StepCount++
+	step := simulation.StepCount
+
+	event := events.StepEvent{
+		Step:     step,
+		SimTimeS: simulation.Scale.SimTime(step),
+		Added:    []events.LinkRef{},
+		Removed:  []events.LinkRef{},
+	}
+	if !shard.Enabled() {
+		simulation.Links.Update(simulation.Nodes)
+		event.Added, event.Removed = simulation.Links.Changes()
+		simulation.Mutex.Unlock()
+		return event
+	}
+	simulation.Mutex.Unlock()
+
+	ctx := context.Background()
+	if err := shard.Advance(ctx, step); err != nil {
+		log.Printf("⚠️ Failed to advance shards to step %d: %v", step, err)
+		return event
+	}
+	states, matrix, err := shard.VisibilityMatrix(ctx, step)
+	if err != nil {
+		log.Printf("⚠️ Failed to gather links at step %d: %v", step, err)
+		return event
+	}
+
 	simulation.Mutex.Lock()
 	defer simulation.Mutex.Unlock()
+	simulation.Links.UpdateVisible(shard.ToNodes(states), matrix)
+	event.Added, event.Removed = simulation.Links.Changes()
+	return event
+}
 
-	for _, node := range simulation.Nodes {
-		node.Move()
+// GetDopplerHandler returns range, range-rate and Doppler shift for every link.
+// The carrier frequency is taken from ?carrier_hz= and defaults to S-band.
+func GetDopplerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	carrier := simulation.DefaultCarrierHz
+	if s := r.URL.Query().Get("carrier_hz"); s != "" {
+		var err error
+		if carrier, err = strconv.ParseFloat(s, 64); err != nil || carrier <= 0 {
+			http.Error(w, "carrier_hz must be a positive number", http.StatusBadRequest)
+			return
+		}
 	}
-	w.Write([]byte("OK"))
+
+	if shard.Enabled() {
+		nodes, links, err := shardLinks(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(simulation.Dynamics(nodes, links, simulation.Scale, carrier))
+		return
+	}
+
+	simulation.Mutex.Lock()
+	defer simulation.Mutex.Unlock()
+	json.NewEncoder(w).Encode(simulation.Dynamics(simulation.Nodes, simulation.Links.Links(), simulation.Scale, carrier))
+}
+
+// maxCoverageCellSamples bounds the work of one /coverage request: grid cells
+// times time samples.
+const maxCoverageCellSamples = 2000000
+
+// GetCoverageHandler samples a lat/lon grid and reports how many satellites
+// each cell sees above the elevation mask, at the current step or over a
+// window of sim time (?window_s= sampled every ?interval_s=).
+func GetCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	latStep, err := floatParam(r, "lat_step", 10)
+	if err != nil || latStep <= 0 || latStep > 180 {
+		http.Error(w, "lat_step must be within (0, 180]", http.StatusBadRequest)
+		return
+	}
+	lonStep, err := floatParam(r, "lon_step", 10)
+	if err != nil || lonStep <= 0 || lonStep > 360 {
+		http.Error(w, "lon_step must be within (0, 360]", http.StatusBadRequest)
+		return
+	}
+	mask, err := floatParam(r, "mask_deg", 10)
+	if err != nil || mask < 0 || mask >= 90 {
+		http.Error(w, "mask_deg must be within [0, 90)", http.StatusBadRequest)
+		return
+	}
+	window, err := floatParam(r, "window_s", 0)
+	if err != nil || window < 0 {
+		http.Error(w, "window_s must be a non-negative number", http.StatusBadRequest)
+		return
+	}
+	interval, err := floatParam(r, "interval_s", 60)
+	if err != nil || interval <= 0 {
+		http.Error(w, "interval_s must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	// Counted in floats, so huge windows cannot overflow the step counts
+	windowSteps := math.Floor(window / simulation.Scale.SecondsPerStep)
+	if windowSteps > math.MaxInt32 {
+		http.Error(w, "window_s is too long", http.StatusBadRequest)
+		return
+	}
+	intervalSteps := math.Min(math.Max(math.Floor(interval/simulation.Scale.SecondsPerStep), 1), windowSteps+1)
+	cells := math.Ceil(180/latStep) * math.Ceil(360/lonStep)
+	samples := math.Floor(windowSteps/intervalSteps) + 1
+	if cells*samples > maxCoverageCellSamples {
+		http.Error(w, fmt.Sprintf("grid cells × time samples must not exceed %d", maxCoverageCellSamples), http.StatusBadRequest)
+		return
+	}
+
+	nodes, step, err := snapshotNodes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	opts := simulation.CoverageOptions{
+		LatStepDeg:    latStep,
+		LonStepDeg:    lonStep,
+		MaskDeg:       mask,
+		WindowSteps:   int(windowSteps),
+		IntervalSteps: int(intervalSteps),
+	}
+	json.NewEncoder(w).Encode(simulation.ComputeCoverage(nodes, simulation.Planet(), step, simulation.Scale, opts))
+}
+
+// maxContactSamples bounds the propagation work of one /contacts request.
+const maxContactSamples = 20000
+
+// GetContactsHandler returns the contact plan: the predicted windows in which
+// node pairs can link over the next horizon_s seconds, sampled every interval_s
+// (one step by default).
+func GetContactsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	horizon, err := floatParam(r, "horizon_s", 3600)
+	if err != nil || horizon < 0 {
+		http.Error(w, "horizon_s must be a non-negative number", http.StatusBadRequest)
+		return
+	}
+	interval, err := floatParam(r, "interval_s", 0)
+	if err != nil || interval < 0 {
+		http.Error(w, "interval_s must be a non-negative number", http.StatusBadRequest)
+		return
+	}
+	horizonSteps := int(horizon / simulation.Scale.SecondsPerStep)
+	intervalSteps := max(int(interval/simulation.Scale.SecondsPerStep), 1)
+	if horizonSteps/intervalSteps > maxContactSamples {
+		http.Error(w, fmt.Sprintf("horizon_s / interval_s must not exceed %d samples", maxContactSamples), http.StatusBadRequest)
+		return
+	}
+
+	nodes, step, err := snapshotNodes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	setStepHeaders(w, step)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"step":       step,
+		"sim_time_s": simulation.Scale.SimTime(step),
+		"horizon_s":  float64(horizonSteps) * simulation.Scale.SecondsPerStep,
+		"interval_s": float64(intervalSteps) * simulation.Scale.SecondsPerStep,
+		"contacts":   simulation.ContactPlan(nodes, step, simulation.Scale, horizonSteps, intervalSteps),
+	})
+}
+
+// ShardPositionsHandler returns the state of the nodes owned by this shard at ?step=.
+func ShardPositionsHandler(w http.ResponseWriter, r *http.Request) {
+	simulation.Mutex.Lock()
+	defer simulation.Mutex.Unlock()
+
+	step := simulation.StepCount
+	if s := r.URL.Query().Get("step"); s != "" {
+		var err error
+		if step, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "step must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(shard.LocalPartition(step))
+}
+
+// ShardStepHandler moves this shard to the step given by the designated shard.
+func ShardStepHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	step, err := strconv.Atoi(r.URL.Query().Get("step"))
+	if err != nil {
+		http.Error(w, "step must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	simulation.Mutex.Lock()
+	defer simulation.Mutex.Unlock()
+	simulation.AdvanceTo(step)
+	json.NewEncoder(w).Encode(map[string]interface{}{"shard": shard.Index(), "step": step})
+}
+
+// ShardVisibilityHandler receives the state of all nodes and returns the
+// visibility rows for the nodes owned by this shard.
+func ShardVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var states []shard.NodeState
+	if err := json.NewDecoder(r.Body).Decode(&states); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(shard.LocalRows(states))
+}
+
+// snapshotNodes returns copies of all nodes at the current step, gathered from
+// the other shards when the simulation is sharded, so callers can run long
+// computations without holding simulation.Mutex.
+func snapshotNodes(r *http.Request) ([]*model.Node, int, error) {
+	simulation.Mutex.Lock()
+	step := simulation.StepCount
+	if shard.Enabled() {
+		simulation.Mutex.Unlock()
+		states, err := shard.Nodes(r.Context(), step)
+		if err != nil {
+			return nil, 0, err
+		}
+		return shard.ToNodes(states), step, nil
+	}
+	defer simulation.Mutex.Unlock()
+
+	return simulation.PropagateNodes(simulation.Nodes, 0), step, nil
+}
+
+// requestedStep converts ?t= into a step number. Without t it returns the
+// current step and false. The caller holds simulation.Mutex and keeps it while
+// reading the step's data, so the step reported matches the data.
+func requestedStep(r *http.Request) (int, bool, error) {
+	s := r.URL.Query().Get("t")
+	if s == "" {
+		return simulation.StepCount, false, nil
+	}
+	t, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(t) || math.IsInf(t, 0) {
+		return 0, false, fmt.Errorf("t must be a sim time in seconds")
+	}
+	step, err := simulation.Scale.StepAt(t)
+	if err != nil {
+		return 0, false, err
+	}
+	return step, true, nil
+}
+
+func setStepHeaders(w http.ResponseWriter, step int) {
+	w.Header().Set("X-Sim-Step", strconv.Itoa(step))
+	w.Header().Set("X-Sim-Time", strconv.FormatFloat(simulation.Scale.SimTime(step), 'f', -1, 64))
+}
+
+func floatParam(r *http.Request, name string, def float64) (float64, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func positions(nodes []*model.Node) []map[string]interface{} {
+	positions := make([]map[string]interface{}, len(nodes))
+	for i, node := range nodes {
+		x, y := node.Position()
+		vx, vy := node.Velocity()
+		kmPerUnit, kmPerSec := simulation.Scale.KmPerUnit, simulation.Scale.KmPerUnit/simulation.Scale.SecondsPerStep
+		positions[i] = map[string]interface{}{
+			"id":      node.ID,
+			"name":    node.Name,
+			"x":       x,
+			"y":       y,
+			"vx":      vx,
+			"vy":      vy,
+			"x_km":    x * kmPerUnit,
+			"y_km":    y * kmPerUnit,
+			"vx_km_s": vx * kmPerSec,
+			"vy_km_s": vy * kmPerSec,
+			"ports":   node.Ports,
+			"portgen": node.PortGen,
+		}
+	}
+	return positions
 }
@@ -12,8 +12,10 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"satellite-coms/pkg/discovery/consul"
+	"satellite-coms/pkg/events"
 	discovery "satellite-coms/pkg/registry"
 	"satellite-coms/simulator/handler"
+	"satellite-coms/simulator/shard"
 	"satellite-coms/simulator/simulation"
 )
 
@@ -25,9 +27,21 @@ var (
 )
 
 func main() {
-	var port int
+	var (
+		port, shardIndex, shardCount int
+		scenarioPath                 string
+		seed                         int64
+	)
 	flag.IntVar(&port, "port", 8081, "API handler port")
+	flag.IntVar(&shardIndex, "shard", 0, "Index of the node partition computed by this instance")
+	flag.IntVar(&shardCount, "shards", 1, "Total number of simulator instances sharing the simulation")
+	flag.StringVar(&scenarioPath, "scenario", "", "Scenario JSON file (defaults to the built-in constellation)")
+	flag.Int64Var(&seed, "seed", 1, "Seed used to generate the scenario")
+	flag.DurationVar(&events.StreamRetention, "stream-retention", events.StreamRetention, "How long step events stay in the Redis step stream")
 	flag.Parse()
+	if events.StreamRetention <= 0 {
+		log.Fatalf("❌ -stream-retention must be positive, got %v", events.StreamRetention)
+	}
 
 	log.Printf("🚀 Starting simulator service on port %d", port)
 
@@ -45,7 +59,20 @@ func main() {
 	}
 
 	// 2️⃣ Initialize simulation state
-	simulation.InitSimulation()
+	if scenarioPath != "" {
+		scenario, err := simulation.LoadScenario(scenarioPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to load scenario: %v", err)
+		}
+		if shardCount > 1 {
+			if err := scenario.Shardable(); err != nil {
+				log.Fatalf("❌ Scenario cannot be sharded: %v", err)
+			}
+		}
+		simulation.InitScenario(scenario, seed)
+	} else {
+		simulation.InitSimulation()
+	}
 
 	// 3️⃣ Register with Consul using container hostname
 	registry, err := consul.NewRegistry("localhost:8500")
@@ -65,6 +92,16 @@ func main() {
 	}
 	defer registry.Deregister(ctx, instanceID, serviceName)
 
+	if err := shard.Init(shardIndex, shardCount, registry, serviceAddr); err != nil {
+		log.Fatalf("❌ Failed to configure sharding: %v", err)
+	}
+	if shard.Enabled() {
+		if err := shard.CheckUnique(ctx); err != nil {
+			log.Fatalf("❌ Failed to configure sharding: %v", err)
+		}
+		log.Printf("🧩 Running as shard %d of %d", shardIndex, shardCount)
+	}
+
 	// 4️⃣ Start health reporting loop
 	go func() {
 		for {
@@ -75,31 +112,47 @@ func main() {
 		}
 	}()
 
-	// 5️⃣ Start automatic simulation steps
-	go func() {
-		for {
-			stepAndPublishHandler(dummyResponseWriter{}, nil)
-			time.Sleep(5 * time.Millisecond) // Adjust step speed
-		}
-	}()
+	// 5️⃣ Start automatic simulation steps; other shards follow the designated one
+	if shard.Designated() {
+		go func() {
+			for {
+				stepAndPublishHandler(dummyResponseWriter{}, nil)
+				time.Sleep(5 * time.Millisecond) // Adjust step speed
+			}
+		}()
+	}
 
 	// 6️⃣ HTTP Handlers
 	http.HandleFunc("/positions", handler.GetPositionsHandler)
 	http.HandleFunc("/visibility", handler.GetVisibilityMatrixHandler)
+	http.HandleFunc("/links", handler.GetLinksHandler)
+	http.HandleFunc("/snapshot", handler.GetSnapshotHandler)
+	http.HandleFunc("/units", handler.GetUnitsHandler)
+	http.HandleFunc("/doppler", handler.GetDopplerHandler)
+	http.HandleFunc("/coverage", handler.GetCoverageHandler)
+	http.HandleFunc("/contacts", handler.GetContactsHandler)
 	http.HandleFunc("/step", stepAndPublishHandler)
+	http.HandleFunc("/shard/positions", handler.ShardPositionsHandler)
+	http.HandleFunc("/shard/visibility", handler.ShardVisibilityHandler)
+	http.HandleFunc("/shard/step", handler.ShardStepHandler)
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
 
 	log.Printf("🌐 Simulator HTTP server listening on port %d", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
 }
 
-// stepAndPublishHandler executes a simulation step and publishes an event to Redis
+// stepAndPublishHandler executes a simulation step and appends its event to the Redis step stream
 func stepAndPublishHandler(w http.ResponseWriter, r *http.Request) {
-	handler.StepHandler(w, r)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !shard.Designated() {
+		http.Error(w, "steps are driven by shard 0", http.StatusConflict)
+		return
+	}
+	event := handler.StepSimulation()
+	w.Write([]byte("OK"))
 
-	err := redisClient.Publish(ctx, "simulation.step", "step executed").Err()
-	if err != nil {
-		log.Printf("❌ Failed to publish simulation.step event: %v", err)
+	if err := events.PublishStep(ctx, redisClient, event); err != nil {
+		log.Printf("❌ Failed to publish step %d: %v", event.Step, err)
 	}
 }
 
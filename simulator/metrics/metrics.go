@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"math"
+
+	"satellite-coms/simulator/model"
+)
+
+// StepMetrics summarizes the node-level topology of a single simulation step.
+type StepMetrics struct {
+	Step                 int     `json:"step"`
+	SimTimeS             float64 `json:"sim_time_s"`
+	Links                int     `json:"links"`
+	Components           int     `json:"components"`
+	GroundPairs          int     `json:"ground_pairs"`
+	ReachableGroundPairs int     `json:"reachable_ground_pairs"`
+	GroundReachability   float64 `json:"ground_reachability"`
+	AvgPathHops          float64 `json:"avg_path_hops"`
+}
+
+// Compute derives topology metrics from the nodes and their visibility matrix.
+// A link is counted once per unordered pair of nodes that can see each other.
+func Compute(step int, nodes []*model.Node, matrix [][]bool) StepMetrics {
+	m := StepMetrics{Step: step}
+
+	adj := make([][]int, len(nodes))
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if matrix[i][j] || matrix[j][i] {
+				adj[i] = append(adj[i], j)
+				adj[j] = append(adj[j], i)
+				m.Links++
+			}
+		}
+	}
+
+	component := make([]int, len(nodes))
+	for i := range component {
+		component[i] = -1
+	}
+	for i := range nodes {
+		if component[i] != -1 {
+			continue
+		}
+		for n, d := range hops(adj, i) {
+			if d >= 0 {
+				component[n] = m.Components
+			}
+		}
+		m.Components++
+	}
+
+	var ground []int
+	for i, node := range nodes {
+		if node.IsServer() {
+			ground = append(ground, i)
+		}
+	}
+
+	totalHops := 0
+	for a := 0; a < len(ground); a++ {
+		dist := hops(adj, ground[a])
+		for b := a + 1; b < len(ground); b++ {
+			m.GroundPairs++
+			if d := dist[ground[b]]; d >= 0 {
+				m.ReachableGroundPairs++
+				totalHops += d
+			}
+		}
+	}
+
+	if m.GroundPairs > 0 {
+		m.GroundReachability = float64(m.ReachableGroundPairs) / float64(m.GroundPairs)
+	}
+	if m.ReachableGroundPairs > 0 {
+		m.AvgPathHops = float64(totalHops) / float64(m.ReachableGroundPairs)
+	}
+	return m
+}
+
+// hops returns the hop distance from start to every node, or -1 when unreachable.
+func hops(adj [][]int, start int) []int {
+	dist := make([]int, len(adj))
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[start] = 0
+	queue := []int{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range adj[current] {
+			if dist[neighbor] == -1 {
+				dist[neighbor] = dist[current] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return dist
+}
+
+// Delivery routes demand units of traffic between every pair of ground nodes
+// over the port graph and returns the traffic offered and delivered. Every
+// port forwards at most its node's portgen per step, shared by all traffic
+// crossing it. Pairs are served in order, each along successive widest paths
+// until its demand is met or no capacity is left, so ports, portgen and
+// failures all bound what gets through.
+func Delivery(nodes []*model.Node, matrix [][]bool, demand float64) (offered, delivered float64) {
+	adj := make([][]int, len(nodes))
+	free := make([][]float64, len(nodes))
+	for i, node := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if matrix[i][j] || matrix[j][i] {
+				adj[i] = append(adj[i], j)
+				adj[j] = append(adj[j], i)
+			}
+		}
+		free[i] = make([]float64, node.Ports)
+		for p := range free[i] {
+			free[i][p] = float64(max(node.PortGen, 0))
+		}
+	}
+
+	var ground []int
+	for i, node := range nodes {
+		if node.IsServer() {
+			ground = append(ground, i)
+		}
+	}
+	for a := 0; a < len(ground); a++ {
+		for b := a + 1; b < len(ground); b++ {
+			offered += demand
+			left := demand
+			for left > 0 {
+				path, width := widestPath(adj, free, ground[a], ground[b])
+				if width <= 0 {
+					break
+				}
+				sent := math.Min(width, left)
+				for _, n := range path {
+					free[n][freest(free[n])] -= sent
+				}
+				left -= sent
+			}
+			delivered += demand - left
+		}
+	}
+	return offered, delivered
+}
+
+// widestPath returns the path from a to b whose most loaded node has the most
+// capacity left, and that capacity. A node is crossed through its freest port,
+// since all ports of a node see the same neighbours.
+func widestPath(adj [][]int, free [][]float64, a, b int) ([]int, float64) {
+	width := make([]float64, len(adj))
+	prev := make([]int, len(adj))
+	done := make([]bool, len(adj))
+	for i := range width {
+		width[i], prev[i] = -1, -1
+	}
+	width[a] = portFree(free[a])
+	for {
+		current := -1
+		for i := range width {
+			if !done[i] && width[i] > 0 && (current == -1 || width[i] > width[current]) {
+				current = i
+			}
+		}
+		if current == -1 {
+			return nil, 0
+		}
+		if current == b {
+			break
+		}
+		done[current] = true
+		for _, next := range adj[current] {
+			if w := math.Min(width[current], portFree(free[next])); !done[next] && w > width[next] {
+				width[next], prev[next] = w, current
+			}
+		}
+	}
+
+	var path []int
+	for n := b; n != -1; n = prev[n] {
+		path = append(path, n)
+	}
+	return path, width[b]
+}
+
+// freest returns the port with the most capacity left.
+func freest(ports []float64) int {
+	best := 0
+	for p, f := range ports {
+		if f > ports[best] {
+			best = p
+		}
+	}
+	return best
+}
+
+func portFree(ports []float64) float64 {
+	if len(ports) == 0 {
+		return 0
+	}
+	return ports[freest(ports)]
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import "math"
+
+// Summary holds the mean of a sample and the half-width of its 95% confidence interval.
+type Summary struct {
+	Mean float64 `json:"mean"`
+	CI95 float64 `json:"ci95"`
+}
+
+// Summarize computes the sample mean and a Student's t 95% confidence interval.
+func Summarize(values []float64) Summary {
+	n := float64(len(values))
+	if n == 0 {
+		return Summary{}
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / n
+	if n < 2 {
+		return Summary{Mean: mean}
+	}
+
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(sq / (n - 1))
+	return Summary{Mean: mean, CI95: tCritical95(len(values)-1) * stddev / math.Sqrt(n)}
+}
+
+// t95 holds the two-sided 95% critical values of Student's t for 1 to 30
+// degrees of freedom.
+var t95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tCritical95 returns the two-sided 95% critical value of Student's t. Above
+// the table it uses the Cornish-Fisher expansion around the normal quantile.
+func tCritical95(df int) float64 {
+	if df <= len(t95) {
+		return t95[df-1]
+	}
+	const z = 1.959964
+	v := float64(df)
+	return z + (z*z*z+z)/(4*v) + (5*math.Pow(z, 5)+16*z*z*z+3*z)/(96*v*v)
+}
+
+// RunMetrics averages the per-step metrics of a single simulation run.
+// DeliveryRatio is left to callers that route traffic, see Delivery.
+type RunMetrics struct {
+	Links              float64 `json:"links"`
+	Components         float64 `json:"components"`
+	GroundReachability float64 `json:"ground_reachability"`
+	AvgPathHops        float64 `json:"avg_path_hops"`
+	DeliveryRatio      float64 `json:"delivery_ratio"`
+}
+
+// Average folds the step metrics of one run into run-level means.
+func Average(steps []StepMetrics) RunMetrics {
+	var r RunMetrics
+	if len(steps) == 0 {
+		return r
+	}
+	for _, m := range steps {
+		r.Links += float64(m.Links)
+		r.Components += float64(m.Components)
+		r.GroundReachability += m.GroundReachability
+		r.AvgPathHops += m.AvgPathHops
+	}
+	n := float64(len(steps))
+	r.Links /= n
+	r.Components /= n
+	r.GroundReachability /= n
+	r.AvgPathHops /= n
+	return r
+}
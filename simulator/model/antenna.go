@@ -0,0 +1,71 @@
+package model
+
+import "math"
+
+// Antenna kinds, matching the kind of link a port serves.
+const (
+	AntennaGround = "ground"
+	AntennaISL    = "isl"
+)
+
+// AntennaSpec is the field of view of the antenna behind one port, as a range
+// of off-nadir angles in radians. A nadir-pointing cone starts at 0; a ring
+// around the nadir axis, typical for inter-satellite links, starts above 0.
+type AntennaSpec struct {
+	Kind        string  `json:"kind"`
+	MinOffNadir float64 `json:"min_off_nadir"`
+	MaxOffNadir float64 `json:"max_off_nadir"`
+}
+
+// Covers reports whether a target at the given off-nadir angle is in view.
+func (a AntennaSpec) Covers(offNadir float64) bool {
+	return offNadir >= a.MinOffNadir && offNadir <= a.MaxOffNadir
+}
+
+// HasAntennas reports whether the node's ports have limited fields of view.
+func (n *Node) HasAntennas() bool {
+	return len(n.Antennas) > 0
+}
+
+// OffNadirTo returns the angle between n's nadir and the direction of other.
+func (n *Node) OffNadirTo(other *Node) float64 {
+	return math.Abs(AngleDiff(n.BodyAngleTo(other), math.Pi))
+}
+
+// LinkPorts returns the 1-based ports of n whose antenna can serve a link to
+// other. It returns nil when n has no antennas, meaning any port can.
+func (n *Node) LinkPorts(other *Node) []int {
+	if !n.HasAntennas() {
+		return nil
+	}
+	kind := AntennaISL
+	if other.IsServer() {
+		kind = AntennaGround
+	}
+	offNadir := n.OffNadirTo(other)
+	ports := []int{}
+	for i, antenna := range n.Antennas {
+		if antenna.Kind == kind && antenna.Covers(offNadir) {
+			ports = append(ports, i+1)
+		}
+	}
+	return ports
+}
+
+// CanLink reports whether n and other can form a link: they must be in line of
+// sight and each end needs a port whose antenna sees the other.
+func (n *Node) CanLink(other *Node) bool {
+	return n.CanView(other) && n.AntennasSee(other)
+}
+
+// AntennasSee reports whether each end has a port whose antenna sees the
+// other, ignoring occlusion.
+func (n *Node) AntennasSee(other *Node) bool {
+	if n.HasAntennas() && len(n.LinkPorts(other)) == 0 {
+		return false
+	}
+	if other.HasAntennas() && len(other.LinkPorts(n)) == 0 {
+		return false
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"math"
+	"strings"
 )
 
 type Node struct {
@@ -15,6 +16,10 @@ type Node struct {
 	ThetaSpeed   float64
 	Ports        int
 	PortGen      int
+	TerminalSpec *TerminalSpec
+	Terminals    []*Terminal
+	Antennas     []AntennaSpec
+	PortChannels [][]int
 }
 
 func NewSatellite(name string, parentPlanet *Planet, orbitRadius, orbitTheta, thetaSpeed float64, ports int, portGen int) *Node {
@@ -25,14 +30,34 @@ func NewServer(name string, parentPlanet *Planet, positionTheta float64, ports i
 	return &Node{ID: "srv_" + hashID(name)[:5], Name: name, ParentPlanet: parentPlanet, OrbitRadius: parentPlanet.Radius, OrbitTheta: positionTheta, ThetaSpeed: parentPlanet.ThetaSpeed, Ports: ports, PortGen: portGen}
 }
 
+// IsServer reports whether the node is a ground server rather than a satellite.
+func (n *Node) IsServer() bool {
+	return strings.HasPrefix(n.ID, "srv_")
+}
+
 func (n *Node) Position() (float64, float64) {
 	return n.OrbitRadius * math.Cos(n.OrbitTheta), n.OrbitRadius * math.Sin(n.OrbitTheta)
 }
 
+// Velocity returns the node's velocity in planet radii per step. Nodes move on
+// circular orbits, so the velocity is tangential with magnitude OrbitRadius*ThetaSpeed.
+func (n *Node) Velocity() (float64, float64) {
+	speed := n.OrbitRadius * n.ThetaSpeed
+	return -speed * math.Sin(n.OrbitTheta), speed * math.Cos(n.OrbitTheta)
+}
+
 func (n *Node) Move() {
 	n.OrbitTheta += n.ThetaSpeed
 }
 
+// Propagate returns a copy of the node moved the given number of steps forward
+// (or backward when negative), leaving the node itself untouched.
+func (n *Node) Propagate(steps int) *Node {
+	c := *n
+	c.OrbitTheta += float64(steps) * n.ThetaSpeed
+	return &c
+}
+
 func (n1 *Node) CanView(n2 *Node) bool {
 	x1, y1 := n1.Position()
 	x2, y2 := n2.Position()
@@ -46,6 +71,30 @@ func (n1 *Node) CanView(n2 *Node) bool {
 	return D >= n1.ParentPlanet.Radius || T < 0 || T > n1.ParentPlanet.Radius
 }
 
+// Clearance returns how far the line of sight between n1 and n2 passes above
+// the planet, in planet radii; it is negative when the planet blocks it. Between
+// satellites it is the height of the lowest point of the segment. When either
+// end is a server it is the height of the other end above the server's horizon
+// plane, so it grows with elevation.
+func (n1 *Node) Clearance(n2 *Node) float64 {
+	x1, y1 := n1.Position()
+	x2, y2 := n2.Position()
+	if n2.IsServer() && !n1.IsServer() {
+		x1, y1, x2, y2 = x2, y2, x1, y1
+	}
+	if n1.IsServer() || n2.IsServer() {
+		r := math.Hypot(x1, y1)
+		return ((x2-x1)*x1 + (y2-y1)*y1) / r
+	}
+
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/l))
+	}
+	return math.Hypot(x1+t*dx, y1+t*dy) - n1.ParentPlanet.Radius
+}
+
 func hashID(s string) string {
 	h := sha1.New()
 	h.Write([]byte(s))
@@ -0,0 +1,75 @@
+package model
+
+import "math"
+
+// TerminalSpec describes the inter-satellite link terminals of a satellite.
+// Angles are in radians and rates in radians per step.
+type TerminalSpec struct {
+	Count            int     `json:"count"`
+	SlewRate         float64 `json:"slew_rate"`
+	FieldOfRegard    float64 `json:"field_of_regard"`
+	AcquisitionSteps int     `json:"acquisition_steps"`
+}
+
+// Terminal is one optical ISL terminal. Directions are measured in the
+// satellite body frame, where 0 points radially away from the planet.
+type Terminal struct {
+	Boresight float64
+	Pointing  float64
+	Partner   string
+}
+
+// SetTerminals equips the node with evenly spaced terminals, the first one
+// looking along-track. All terminals start idle, pointing at their boresight.
+func (n *Node) SetTerminals(spec TerminalSpec) {
+	n.TerminalSpec = &spec
+	n.Terminals = make([]*Terminal, spec.Count)
+	for i := range n.Terminals {
+		boresight := normalizeAngle(math.Pi/2 + float64(i)*2*math.Pi/float64(spec.Count))
+		n.Terminals[i] = &Terminal{Boresight: boresight, Pointing: boresight}
+	}
+}
+
+// HasTerminals reports whether the node's links are limited by ISL terminals.
+func (n *Node) HasTerminals() bool {
+	return n.TerminalSpec != nil
+}
+
+// BodyAngleTo returns the direction of other as seen from n, in n's body frame.
+func (n *Node) BodyAngleTo(other *Node) float64 {
+	x1, y1 := n.Position()
+	x2, y2 := other.Position()
+	return normalizeAngle(math.Atan2(y2-y1, x2-x1) - n.OrbitTheta)
+}
+
+// CanPoint reports whether the terminal's field of regard contains the body-frame angle.
+func (t *Terminal) CanPoint(angle, fieldOfRegard float64) bool {
+	return math.Abs(AngleDiff(angle, t.Boresight)) <= fieldOfRegard/2
+}
+
+// SlewTowards turns the terminal at most maxStep radians towards angle and
+// reports whether it now points at it.
+func (t *Terminal) SlewTowards(angle, maxStep float64) bool {
+	diff := AngleDiff(angle, t.Pointing)
+	if math.Abs(diff) <= maxStep {
+		t.Pointing = normalizeAngle(angle)
+		return true
+	}
+	t.Pointing = normalizeAngle(t.Pointing + math.Copysign(maxStep, diff))
+	return false
+}
+
+// AngleDiff returns a-b wrapped to (-π, π].
+func AngleDiff(a, b float64) float64 {
+	return normalizeAngle(a - b)
+}
+
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a > math.Pi {
+		a -= 2 * math.Pi
+	} else if a <= -math.Pi {
+		a += 2 * math.Pi
+	}
+	return a
+}
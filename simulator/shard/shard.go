@@ -0,0 +1,309 @@
+package shard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	discovery "satellite-coms/pkg/registry"
+	"satellite-coms/simulator/model"
+	"satellite-coms/simulator/simulation"
+)
+
+// Nodes are partitioned across shards by their index in simulation.Nodes:
+// shard i owns every node whose index is congruent to i modulo the shard count,
+// and computes the visibility rows of those nodes. Every shard builds the same
+// scenario, so all shards agree on the indices, and keeps every node's
+// position; shard 0 is the designated shard that drives the steps and tracks
+// the links.
+var (
+	index    = 0
+	count    = 1
+	selfAddr string
+	registry discovery.Registry
+	client   = &http.Client{Timeout: 5 * time.Second}
+)
+
+// NodeState is the orbital state of one node as exchanged between shards.
+type NodeState struct {
+	Index       int     `json:"index"`
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	OrbitRadius float64 `json:"orbit_radius"`
+	OrbitTheta  float64 `json:"orbit_theta"`
+	ThetaSpeed  float64 `json:"theta_speed"`
+	Ports       int     `json:"ports"`
+	PortGen     int     `json:"portgen"`
+}
+
+// Partition is the state a shard reports for the nodes it owns at a given step.
+type Partition struct {
+	Shard  int         `json:"shard"`
+	Shards int         `json:"shards"`
+	Step   int         `json:"step"`
+	Nodes  []NodeState `json:"nodes"`
+}
+
+// VisibilityRows holds the visibility rows a shard computed for its own nodes.
+type VisibilityRows struct {
+	Shard int            `json:"shard"`
+	Rows  map[int][]bool `json:"rows"`
+}
+
+// Init configures this instance as shard i of n. Peers are discovered through
+// the registry under the same service name as this instance.
+func Init(i, n int, reg discovery.Registry, addr string) error {
+	if n < 1 || i < 0 || i >= n {
+		return fmt.Errorf("invalid shard %d of %d", i, n)
+	}
+	index, count, registry, selfAddr = i, n, reg, addr
+	return nil
+}
+
+// Enabled reports whether the simulation is split across several instances.
+func Enabled() bool {
+	return count > 1
+}
+
+// Index returns this instance's shard index.
+func Index() int {
+	return index
+}
+
+// Owns reports whether the node at position i of simulation.Nodes belongs to this shard.
+func Owns(i int) bool {
+	return i%count == index
+}
+
+// Designated reports whether this instance drives the steps and tracks the
+// links: shard 0, or the only instance when the simulation is not sharded.
+func Designated() bool {
+	return index == 0
+}
+
+// LocalPartition propagates the nodes owned by this shard to the given step.
+// The caller must hold simulation.Mutex.
+func LocalPartition(step int) Partition {
+	p := Partition{Shard: index, Shards: count, Step: step}
+	for i, node := range simulation.Nodes {
+		if !Owns(i) {
+			continue
+		}
+		n := node.Propagate(step - simulation.StepCount)
+		p.Nodes = append(p.Nodes, NodeState{
+			Index: i, ID: n.ID, Name: n.Name,
+			OrbitRadius: n.OrbitRadius, OrbitTheta: n.OrbitTheta, ThetaSpeed: n.ThetaSpeed,
+			Ports: n.Ports, PortGen: n.PortGen,
+		})
+	}
+	return p
+}
+
+// LocalRows computes the visibility rows of the nodes owned by this shard
+// against every node, using the full set of node states at one step.
+func LocalRows(states []NodeState) VisibilityRows {
+	nodes := ToNodes(states)
+	rows := VisibilityRows{Shard: index, Rows: make(map[int][]bool)}
+	for i, node := range nodes {
+		if !Owns(i) {
+			continue
+		}
+		row := make([]bool, len(nodes))
+		for j, other := range nodes {
+			if i != j {
+				row[j] = node.CanLink(other)
+			}
+		}
+		rows.Rows[i] = row
+	}
+	return rows
+}
+
+// Nodes gathers the state of every node at the given step from all shards and
+// returns it in simulation.Nodes order.
+func Nodes(ctx context.Context, step int) ([]NodeState, error) {
+	simulation.Mutex.Lock()
+	local := LocalPartition(step)
+	total := len(simulation.Nodes)
+	simulation.Mutex.Unlock()
+
+	states := make([]NodeState, total)
+	seen := map[int]bool{index: true}
+	for _, s := range local.Nodes {
+		states[s.Index] = s
+	}
+
+	peers, err := peerAddrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range peers {
+		var p Partition
+		if err := getJSON(ctx, fmt.Sprintf("http://%s/shard/positions?step=%d", addr, step), &p); err != nil {
+			return nil, fmt.Errorf("shard %s: %w", addr, err)
+		}
+		if p.Shards != count {
+			return nil, fmt.Errorf("shard %s reports %d shards, expected %d", addr, p.Shards, count)
+		}
+		if seen[p.Shard] {
+			return nil, fmt.Errorf("shard %s reports index %d, which another instance already has", addr, p.Shard)
+		}
+		seen[p.Shard] = true
+		for _, s := range p.Nodes {
+			if s.Index < 0 || s.Index >= total {
+				return nil, fmt.Errorf("shard %s reports unknown node index %d", addr, s.Index)
+			}
+			states[s.Index] = s
+		}
+	}
+	if len(seen) != count {
+		return nil, fmt.Errorf("only %d of %d shards are available", len(seen), count)
+	}
+	return states, nil
+}
+
+// CheckUnique fails when a registered peer already runs with this shard
+// index or a different shard count. Peers that do not answer yet are skipped;
+// they check against this instance when they start.
+func CheckUnique(ctx context.Context) error {
+	peers, err := peerAddrs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, addr := range peers {
+		var p Partition
+		if err := getJSON(ctx, fmt.Sprintf("http://%s/shard/positions", addr), &p); err != nil {
+			continue
+		}
+		if p.Shards != count {
+			return fmt.Errorf("shard %s reports %d shards, expected %d", addr, p.Shards, count)
+		}
+		if p.Shard == index {
+			return fmt.Errorf("shard %s already runs as shard %d", addr, index)
+		}
+	}
+	return nil
+}
+
+// Advance moves every other shard to the given step, so all shards follow the
+// designated shard's clock.
+func Advance(ctx context.Context, step int) error {
+	peers, err := peerAddrs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, addr := range peers {
+		var ack map[string]interface{}
+		if err := postJSON(ctx, fmt.Sprintf("http://%s/shard/step?step=%d", addr, step), nil, &ack); err != nil {
+			return fmt.Errorf("shard %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// VisibilityMatrix gathers the full visibility matrix at the given step. Every
+// shard receives the node states of all shards (the boundary data) and returns
+// the rows for the nodes it owns.
+func VisibilityMatrix(ctx context.Context, step int) ([]NodeState, [][]bool, error) {
+	states, err := Nodes(ctx, step)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matrix := make([][]bool, len(states))
+	for i, row := range LocalRows(states).Rows {
+		matrix[i] = row
+	}
+
+	peers, err := peerAddrs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(states)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, addr := range peers {
+		var rows VisibilityRows
+		if err := postJSON(ctx, fmt.Sprintf("http://%s/shard/visibility", addr), body, &rows); err != nil {
+			return nil, nil, fmt.Errorf("shard %s: %w", addr, err)
+		}
+		for i, row := range rows.Rows {
+			if i < 0 || i >= len(matrix) || len(row) != len(states) {
+				return nil, nil, fmt.Errorf("shard %s returned a malformed row %d", addr, i)
+			}
+			matrix[i] = row
+		}
+	}
+	for i, row := range matrix {
+		if row == nil {
+			return nil, nil, fmt.Errorf("no shard returned visibility for node %d", i)
+		}
+	}
+	return states, matrix, nil
+}
+
+// ToNodes rebuilds model nodes from exchanged states, bound to the local planet.
+// Static configuration such as antennas is taken from the local copy of the
+// scenario, which every shard shares.
+func ToNodes(states []NodeState) []*model.Node {
+	nodes := make([]*model.Node, len(states))
+	for i, s := range states {
+		nodes[i] = &model.Node{
+			ID: s.ID, Name: s.Name, ParentPlanet: simulation.Planet(),
+			OrbitRadius: s.OrbitRadius, OrbitTheta: s.OrbitTheta, ThetaSpeed: s.ThetaSpeed,
+			Ports: s.Ports, PortGen: s.PortGen,
+		}
+		if s.Index < len(simulation.Nodes) && simulation.Nodes[s.Index].ID == s.ID {
+			nodes[i].Antennas = simulation.Nodes[s.Index].Antennas
+		}
+	}
+	return nodes
+}
+
+// peerAddrs returns the addresses of the other simulator instances.
+func peerAddrs(ctx context.Context) ([]string, error) {
+	addrs, err := registry.ServiceAddress(ctx, "simulator")
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover simulator shards: %w", err)
+	}
+	var peers []string
+	for _, addr := range addrs {
+		if addr != selfAddr {
+			peers = append(peers, addr)
+		}
+	}
+	return peers, nil
+}
+
+func getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return do(req, out)
+}
+
+func postJSON(ctx context.Context, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(req, out)
+}
+
+func do(req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
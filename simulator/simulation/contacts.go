@@ -0,0 +1,75 @@
+package simulation
+
+import (
+	"sort"
+
+	"satellite-coms/simulator/model"
+)
+
+// Contact is a window during which two nodes are predicted to be able to
+// link. StartStep and EndStep are the first and last sampled steps in view; a
+// contact open at the start of the plan may have begun earlier, and one open at
+// its end may last longer.
+type Contact struct {
+	A          string  `json:"a"`
+	B          string  `json:"b"`
+	StartStep  int     `json:"start_step"`
+	EndStep    int     `json:"end_step"`
+	StartS     float64 `json:"start_s"`
+	EndS       float64 `json:"end_s"`
+	MaxRangeKm float64 `json:"max_range_km"`
+}
+
+// ContactPlan predicts the contacts between the nodes over the next
+// horizonSteps steps, sampling every intervalSteps. Visibility is geometric,
+// as in VisibilityMatrix. The nodes are propagated on copies.
+func ContactPlan(nodes []*model.Node, step int, units Units, horizonSteps, intervalSteps int) []Contact {
+	interval := max(intervalSteps, 1)
+	open := make(map[[2]int]*Contact)
+	var plan []Contact
+
+	for offset := 0; offset <= horizonSteps; offset += interval {
+		at := PropagateNodes(nodes, offset)
+		matrix := VisibilityMatrix(at)
+		for i := range at {
+			for j := i + 1; j < len(at); j++ {
+				key := [2]int{i, j}
+				c, ok := open[key]
+				if !matrix[i][j] {
+					if ok {
+						plan = append(plan, *c)
+						delete(open, key)
+					}
+					continue
+				}
+				if !ok {
+					a, b := at[i].ID, at[j].ID
+					if a > b {
+						a, b = b, a
+					}
+					c = &Contact{A: a, B: b, StartStep: step + offset, StartS: units.SimTime(step + offset)}
+					open[key] = c
+				}
+				c.EndStep = step + offset
+				c.EndS = units.SimTime(step + offset)
+				if d := distance(at[i], at[j]) * units.KmPerUnit; d > c.MaxRangeKm {
+					c.MaxRangeKm = d
+				}
+			}
+		}
+	}
+	for _, c := range open {
+		plan = append(plan, *c)
+	}
+
+	sort.Slice(plan, func(x, y int) bool {
+		if plan[x].StartStep != plan[y].StartStep {
+			return plan[x].StartStep < plan[y].StartStep
+		}
+		if plan[x].A != plan[y].A {
+			return plan[x].A < plan[y].A
+		}
+		return plan[x].B < plan[y].B
+	})
+	return plan
+}
@@ -0,0 +1,140 @@
+package simulation
+
+import (
+	"math"
+
+	"satellite-coms/simulator/model"
+)
+
+// CoverageOptions controls the surface grid and the time window of a coverage run.
+// The model plane is the planet's equatorial plane; ground cells spread over
+// the whole sphere and rotate with the planet.
+type CoverageOptions struct {
+	LatStepDeg    float64
+	LonStepDeg    float64
+	MaskDeg       float64
+	WindowSteps   int
+	IntervalSteps int
+}
+
+// CoverageCell reports one grid cell, identified by its centre.
+type CoverageCell struct {
+	Lat             float64 `json:"lat"`
+	Lon             float64 `json:"lon"`
+	Visible         int     `json:"visible"`
+	CoveredFraction float64 `json:"covered_fraction"`
+	MaxGapS         float64 `json:"max_gap_s"`
+}
+
+// Coverage summarizes how much of the planet the satellites cover. Percentages
+// are weighted by cell area. Visible is the satellite count at the first sample.
+type Coverage struct {
+	Step            int            `json:"step"`
+	SimTimeS        float64        `json:"sim_time_s"`
+	WindowS         float64        `json:"window_s"`
+	Samples         int            `json:"samples"`
+	MaskDeg         float64        `json:"elevation_mask_deg"`
+	CoveragePercent float64        `json:"coverage_percent"`
+	MaxRevisitGapS  float64        `json:"max_revisit_gap_s"`
+	Cells           []CoverageCell `json:"cells"`
+}
+
+// ComputeCoverage samples the grid from step over the options window. The nodes
+// are propagated on copies, so the caller's nodes are left untouched.
+func ComputeCoverage(nodes []*model.Node, p *model.Planet, step int, units Units, opts CoverageOptions) Coverage {
+	interval := max(opts.IntervalSteps, 1)
+	samples := opts.WindowSteps/interval + 1
+
+	c := Coverage{
+		Step:     step,
+		SimTimeS: units.SimTime(step),
+		WindowS:  float64(opts.WindowSteps) * units.SecondsPerStep,
+		Samples:  samples,
+		MaskDeg:  opts.MaskDeg,
+	}
+
+	var sats []*model.Node
+	for _, node := range nodes {
+		if !node.IsServer() {
+			sats = append(sats, node)
+		}
+	}
+
+	for lat := -90 + opts.LatStepDeg/2; lat < 90; lat += opts.LatStepDeg {
+		for lon := -180 + opts.LonStepDeg/2; lon < 180; lon += opts.LonStepDeg {
+			c.Cells = append(c.Cells, CoverageCell{Lat: lat, Lon: lon})
+		}
+	}
+
+	covered := make([]int, len(c.Cells))
+	gap := make([]int, len(c.Cells))
+	maxGap := make([]int, len(c.Cells))
+	sinMask := math.Sin(opts.MaskDeg * math.Pi / 180)
+
+	for s := 0; s < samples; s++ {
+		offset := s * interval
+		rotation := p.ThetaSpeed * float64(step+offset)
+		positions := make([][2]float64, len(sats))
+		for i, sat := range sats {
+			x, y := sat.Propagate(offset).Position()
+			positions[i] = [2]float64{x, y}
+		}
+
+		for i := range c.Cells {
+			cell := &c.Cells[i]
+			visible := countVisible(cell.Lat, cell.Lon, rotation, p.Radius, sinMask, positions)
+			if s == 0 {
+				cell.Visible = visible
+			}
+			if visible > 0 {
+				covered[i]++
+				gap[i] = 0
+			} else {
+				gap[i]++
+				maxGap[i] = max(maxGap[i], gap[i])
+			}
+		}
+	}
+
+	totalWeight, coveredWeight := 0.0, 0.0
+	for i := range c.Cells {
+		cell := &c.Cells[i]
+		cell.CoveredFraction = float64(covered[i]) / float64(samples)
+		if samples > 1 {
+			cell.MaxGapS = float64(maxGap[i]*interval) * units.SecondsPerStep
+		}
+		c.MaxRevisitGapS = math.Max(c.MaxRevisitGapS, cell.MaxGapS)
+
+		weight := math.Cos(cell.Lat * math.Pi / 180)
+		totalWeight += weight
+		coveredWeight += weight * cell.CoveredFraction
+	}
+	if totalWeight > 0 {
+		c.CoveragePercent = 100 * coveredWeight / totalWeight
+	}
+	return c
+}
+
+// countVisible counts the satellites above the elevation mask of a ground
+// point. The point lies on a sphere of the planet radius; satellites lie in the
+// equatorial plane.
+func countVisible(latDeg, lonDeg, rotation, radius, sinMask float64, sats [][2]float64) int {
+	lat := latDeg * math.Pi / 180
+	lon := lonDeg*math.Pi/180 + rotation
+	ux, uy, uz := math.Cos(lat)*math.Cos(lon), math.Cos(lat)*math.Sin(lon), math.Sin(lat)
+	gx, gy, gz := radius*ux, radius*uy, radius*uz
+
+	count := 0
+	for _, s := range sats {
+		dx, dy, dz := s[0]-gx, s[1]-gy, -gz
+		d := math.Sqrt(dx*dx + dy*dy + dz*dz)
+		if d == 0 {
+			continue
+		}
+		// sine of the elevation is the component of the line of sight along the local vertical
+		if (dx*ux+dy*uy+dz*uz)/d >= sinMask {
+			count++
+		}
+	}
+	return count
+}
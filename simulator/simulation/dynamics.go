@@ -0,0 +1,69 @@
+package simulation
+
+import (
+	"math"
+
+	"satellite-coms/simulator/model"
+)
+
+// LinkDynamics is the relative motion of the two ends of a link.
+//
+// Range-rate is the time derivative of the distance from A to B: positive when
+// the nodes move apart, negative when they approach. The Doppler shift is the
+// first-order shift seen by B for a carrier transmitted by A,
+// -carrier*rangeRate/c, so it is negative (red-shifted) while the range grows
+// and positive (blue-shifted) while it shrinks.
+type LinkDynamics struct {
+	A               string  `json:"a"`
+	B               string  `json:"b"`
+	State           string  `json:"state"`
+	RangeKm         float64 `json:"range_km"`
+	RangeRateKmPerS float64 `json:"range_rate_km_s"`
+	CarrierHz       float64 `json:"carrier_hz"`
+	DopplerShiftHz  float64 `json:"doppler_shift_hz"`
+}
+
+// RangeRate returns the distance between a and b and its rate of change, in
+// planet radii and planet radii per step.
+func RangeRate(a, b *model.Node) (float64, float64) {
+	x1, y1 := a.Position()
+	x2, y2 := b.Position()
+	vx1, vy1 := a.Velocity()
+	vx2, vy2 := b.Velocity()
+
+	rx, ry := x2-x1, y2-y1
+	vx, vy := vx2-vx1, vy2-vy1
+	r := math.Hypot(rx, ry)
+	if r == 0 {
+		return 0, 0
+	}
+	return r, (rx*vx + ry*vy) / r
+}
+
+// Dynamics computes range, range-rate and Doppler shift for every link.
+func Dynamics(nodes []*model.Node, links []Link, units Units, carrierHz float64) []LinkDynamics {
+	byID := make(map[string]*model.Node, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+
+	out := make([]LinkDynamics, 0, len(links))
+	for _, link := range links {
+		a, b := byID[link.A], byID[link.B]
+		if a == nil || b == nil {
+			continue
+		}
+		r, rate := RangeRate(a, b)
+		rateKmPerS := rate * units.KmPerUnit / units.SecondsPerStep
+		out = append(out, LinkDynamics{
+			A:               link.A,
+			B:               link.B,
+			State:           link.State,
+			RangeKm:         r * units.KmPerUnit,
+			RangeRateKmPerS: rateKmPerS,
+			CarrierHz:       carrierHz,
+			DopplerShiftHz:  -carrierHz * rateKmPerS / SpeedOfLightKmPerSec,
+		})
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+
+	"satellite-coms/simulator/model"
+)
+
+const tolerance = 1e-12
+
+func TestVelocityOnCircularOrbit(t *testing.T) {
+	p := model.NewPlanet("Earth", 1, math.Pi/20480)
+	for _, theta := range []float64{0, 0.3, math.Pi / 2, 2.5, math.Pi, 4.4} {
+		for _, orbit := range []struct{ radius, speed float64 }{
+			{math.Sqrt(2), math.Pi / 5120},
+			{3, math.Pi / 10240},
+			{1.1, -0.002},
+		} {
+			sat := model.NewSatellite("sat", p, orbit.radius, theta, orbit.speed, 1, 1)
+			x, y := sat.Position()
+			vx, vy := sat.Velocity()
+
+			if got, want := math.Hypot(vx, vy), math.Abs(orbit.speed)*orbit.radius; math.Abs(got-want) > tolerance {
+				t.Errorf("r=%v θ=%v: speed %v, want ωr = %v", orbit.radius, theta, got, want)
+			}
+			if dot := x*vx + y*vy; math.Abs(dot) > tolerance {
+				t.Errorf("r=%v θ=%v: velocity not perpendicular to position, dot product %v", orbit.radius, theta, dot)
+			}
+			// Counter-clockwise motion for positive angular velocity
+			if cross := x*vy - y*vx; math.Signbit(cross) != math.Signbit(orbit.speed) {
+				t.Errorf("r=%v θ=%v: angular momentum %v has the wrong sign", orbit.radius, theta, cross)
+			}
+		}
+	}
+}
+
+func TestRangeRateCoOrbital(t *testing.T) {
+	p := model.NewPlanet("Earth", 1, math.Pi/20480)
+	a := model.NewSatellite("a", p, 3, 0.4, math.Pi/10240, 1, 1)
+	b := model.NewSatellite("b", p, 3, 0.4+2*math.Pi/3, math.Pi/10240, 1, 1)
+
+	for step := 0; step < 100; step++ {
+		r, rate := RangeRate(a, b)
+		if want := 2 * 3 * math.Sin(math.Pi/3); math.Abs(r-want) > tolerance {
+			t.Fatalf("step %d: range %v, want chord %v", step, r, want)
+		}
+		if math.Abs(rate) > tolerance {
+			t.Fatalf("step %d: range rate %v between co-orbital nodes, want 0", step, rate)
+		}
+		a.Move()
+		b.Move()
+	}
+}
+
+func TestRangeRateMatchesAnalytic(t *testing.T) {
+	p := model.NewPlanet("Earth", 1, 0)
+	r1, w1 := math.Sqrt(2), math.Pi/5120
+	r2, w2 := 3.0, math.Pi/10240
+	a := model.NewSatellite("a", p, r1, 0.1, w1, 1, 1)
+	b := model.NewSatellite("b", p, r2, 1.7, w2, 1, 1)
+
+	// d² = r1² + r2² - 2 r1 r2 cos φ with φ = θb - θa, so
+	// ḋ = r1 r2 sin φ (ωb - ωa) / d
+	for step := 0; step < 1000; step += 37 {
+		phi := b.OrbitTheta - a.OrbitTheta
+		d := math.Sqrt(r1*r1 + r2*r2 - 2*r1*r2*math.Cos(phi))
+		want := r1 * r2 * math.Sin(phi) * (w2 - w1) / d
+
+		r, rate := RangeRate(a, b)
+		if math.Abs(r-d) > tolerance {
+			t.Errorf("step %d: range %v, want %v", step, r, d)
+		}
+		if math.Abs(rate-want) > tolerance {
+			t.Errorf("step %d: range rate %v, want %v", step, rate, want)
+		}
+		for i := 0; i < 37; i++ {
+			a.Move()
+			b.Move()
+		}
+	}
+}
+
+func TestDynamicsDopplerSign(t *testing.T) {
+	p := model.NewPlanet("Earth", 1, 0)
+	a := model.NewSatellite("a", p, math.Sqrt(2), 0, math.Pi/5120, 1, 1)
+	b := model.NewSatellite("b", p, 3, 0.5, math.Pi/10240, 1, 1)
+	units := EarthUnits(p)
+
+	out := Dynamics([]*model.Node{a, b}, []Link{{A: a.ID, B: b.ID, State: LinkActive}}, units, DefaultCarrierHz)
+	if len(out) != 1 {
+		t.Fatalf("got %d link dynamics, want 1", len(out))
+	}
+	d := out[0]
+	_, rate := RangeRate(a, b)
+	if want := rate * units.KmPerUnit / units.SecondsPerStep; math.Abs(d.RangeRateKmPerS-want) > tolerance {
+		t.Errorf("range rate %v km/s, want %v", d.RangeRateKmPerS, want)
+	}
+	if want := -DefaultCarrierHz * d.RangeRateKmPerS / SpeedOfLightKmPerSec; math.Abs(d.DopplerShiftHz-want) > 1e-6 {
+		t.Errorf("Doppler shift %v Hz, want %v", d.DopplerShiftHz, want)
+	}
+	if (d.RangeRateKmPerS > 0) == (d.DopplerShiftHz > 0) {
+		t.Errorf("receding link must be red-shifted: range rate %v, shift %v", d.RangeRateKmPerS, d.DopplerShiftHz)
+	}
+}
@@ -0,0 +1,67 @@
+package simulation
+
+import "satellite-coms/simulator/model"
+
+// HysteresisConfig keeps links from flapping at the occlusion boundary. A pair
+// comes up once its line of sight clears the planet by UpMargin and goes down
+// when the clearance drops below DownMargin. Margins are in planet radii; see
+// model.Node.Clearance. When MinContactSteps is set, a contact is ignored
+// unless it is predicted to last at least that many steps.
+type HysteresisConfig struct {
+	UpMargin        float64 `json:"up_margin"`
+	DownMargin      float64 `json:"down_margin"`
+	MinContactSteps int     `json:"min_contact_steps"`
+}
+
+// visibility returns the pairs that can link this step. Without hysteresis it
+// is the geometric visibility; with it, each pair keeps its contact state
+// across steps and switches only when it crosses the margin for its state.
+func (l *LinkLayer) visibility(nodes []*model.Node) [][]bool {
+	if l.Hysteresis == nil {
+		return VisibilityMatrix(nodes)
+	}
+	h := l.Hysteresis
+
+	matrix := make([][]bool, len(nodes))
+	for i := range matrix {
+		matrix[i] = make([]bool, len(nodes))
+	}
+	contacts := make(map[[2]string]bool)
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			a, b := nodes[i], nodes[j]
+			key := pairKey(a.ID, b.ID)
+			var up bool
+			if l.contacts[key] {
+				up = h.holds(a, b)
+			} else {
+				up = h.rises(a, b) && h.lasts(a, b)
+			}
+			if up {
+				matrix[i][j], matrix[j][i] = true, true
+				contacts[key] = true
+			}
+		}
+	}
+	l.contacts = contacts
+	return matrix
+}
+
+func (h *HysteresisConfig) rises(a, b *model.Node) bool {
+	return a.Clearance(b) >= h.UpMargin && a.AntennasSee(b)
+}
+
+func (h *HysteresisConfig) holds(a, b *model.Node) bool {
+	return a.Clearance(b) >= h.DownMargin && a.AntennasSee(b)
+}
+
+// lasts reports whether a contact starting now is predicted to hold for
+// MinContactSteps, propagating copies of both nodes.
+func (h *HysteresisConfig) lasts(a, b *model.Node) bool {
+	for s := 1; s < h.MinContactSteps; s++ {
+		if !h.holds(a.Propagate(s), b.Propagate(s)) {
+			return false
+		}
+	}
+	return true
+}
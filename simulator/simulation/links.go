@@ -0,0 +1,316 @@
+package simulation
+
+import (
+	"math"
+	"sort"
+
+	"satellite-coms/pkg/events"
+	"satellite-coms/simulator/model"
+)
+
+// Link states reported in the link output.
+const (
+	LinkActive     = "active"
+	LinkSlewing    = "slewing"
+	LinkAcquiring  = "acquiring"
+	LinkUnassigned = "unassigned"
+)
+
+// Link is a line-of-sight pair of nodes. Ends equipped with ISL terminals must
+// point a terminal at each other and finish acquisition before the link is usable.
+type Link struct {
+	A          string  `json:"a"`
+	B          string  `json:"b"`
+	State      string  `json:"state"`
+	Distance   float64 `json:"distance"`
+	DistanceKm float64 `json:"distance_km"`
+	TerminalA  int     `json:"terminal_a"`
+	TerminalB  int     `json:"terminal_b"`
+	Remaining  int     `json:"acquisition_remaining"`
+
+	// PortsA and PortsB list the ports whose antenna can serve the link;
+	// they are omitted when every port can
+	PortsA []int `json:"ports_a,omitempty"`
+	PortsB []int `json:"ports_b,omitempty"`
+
+	// Channel, SINRdB and Degraded are only set when the spectrum model applies
+	Channel  int      `json:"channel,omitempty"`
+	SINRdB   *float64 `json:"sinr_db,omitempty"`
+	Degraded bool     `json:"degraded,omitempty"`
+}
+
+// LinkLayer turns geometric visibility into usable links, keeping the
+// terminal assignment and acquisition progress of every pair across steps.
+type LinkLayer struct {
+	tracked  map[[2]string]*Link
+	contacts map[[2]string]bool
+	assigned map[[2]string]channelAssignment
+	links    []Link
+	matrix   [][]bool
+
+	active  map[[2]string]bool
+	added   []events.LinkRef
+	removed []events.LinkRef
+
+	// Spectrum enables channel assignment and interference; nil disables it
+	Spectrum *SpectrumConfig
+
+	// Hysteresis enables link-up and link-down margins; nil disables it
+	Hysteresis *HysteresisConfig
+
+	// Units converts link distances to km
+	Units Units
+}
+
+func NewLinkLayer() *LinkLayer {
+	return &LinkLayer{tracked: make(map[[2]string]*Link), active: make(map[[2]string]bool)}
+}
+
+// Changes returns the usable links that appeared and disappeared in the last
+// Update, sorted by node IDs.
+func (l *LinkLayer) Changes() (added, removed []events.LinkRef) {
+	return l.added, l.removed
+}
+
+// Matrix returns the usable links computed by the last Update, indexed like the nodes.
+func (l *LinkLayer) Matrix() [][]bool {
+	return l.matrix
+}
+
+// Links returns every line-of-sight pair seen by the last Update with its state.
+func (l *LinkLayer) Links() []Link {
+	return l.links
+}
+
+// Update recomputes the links for the current node positions.
+func (l *LinkLayer) Update(nodes []*model.Node) {
+	l.UpdateVisible(nodes, l.visibility(nodes))
+}
+
+// UpdateVisible recomputes the links from a visibility matrix computed
+// elsewhere, such as the one gathered from all shards.
+func (l *LinkLayer) UpdateVisible(nodes []*model.Node, visible [][]bool) {
+	byID := make(map[string]*model.Node, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+
+	// Tear down links that lost line of sight or left a terminal's field of regard
+	for key, link := range l.tracked {
+		a, okA := byID[link.A]
+		b, okB := byID[link.B]
+		if !okA || !okB || !visible[index[link.A]][index[link.B]] ||
+			!inRegard(a, link.TerminalA, b) || !inRegard(b, link.TerminalB, a) {
+			release(a, link.TerminalA)
+			release(b, link.TerminalB)
+			delete(l.tracked, key)
+		}
+	}
+
+	// Offer free terminals to new pairs, nearest pairs first
+	type candidate struct {
+		i, j     int
+		distance float64
+	}
+	var candidates []candidate
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if !visible[i][j] || !needsTerminals(nodes[i], nodes[j]) {
+				continue
+			}
+			if _, ok := l.tracked[pairKey(nodes[i].ID, nodes[j].ID)]; ok {
+				continue
+			}
+			candidates = append(candidates, candidate{i, j, distance(nodes[i], nodes[j])})
+		}
+	}
+	sort.SliceStable(candidates, func(x, y int) bool { return candidates[x].distance < candidates[y].distance })
+
+	for _, c := range candidates {
+		a, b := nodes[c.i], nodes[c.j]
+		ta, tb := freeTerminal(a, b), freeTerminal(b, a)
+		if ta == -2 || tb == -2 {
+			continue
+		}
+		key := pairKey(a.ID, b.ID)
+		if key[0] != a.ID {
+			a, b, ta, tb = b, a, tb, ta
+		}
+		claim(a, ta, b.ID)
+		claim(b, tb, a.ID)
+		l.tracked[key] = &Link{A: a.ID, B: b.ID, State: LinkSlewing, TerminalA: ta, TerminalB: tb}
+	}
+
+	// Slew, acquire and track
+	for _, link := range l.tracked {
+		a, b := byID[link.A], byID[link.B]
+		alignedA := slew(a, link.TerminalA, b)
+		alignedB := slew(b, link.TerminalB, a)
+		if !alignedA || !alignedB {
+			link.State = LinkSlewing
+			link.Remaining = 0
+			continue
+		}
+		if link.State == LinkSlewing {
+			link.State = LinkAcquiring
+			link.Remaining = max(acquisitionSteps(a, link.TerminalA), acquisitionSteps(b, link.TerminalB))
+		} else if link.State == LinkAcquiring {
+			link.Remaining--
+		}
+		if link.State == LinkAcquiring && link.Remaining <= 0 {
+			link.State = LinkActive
+			link.Remaining = 0
+		}
+	}
+
+	l.links = nil
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if !visible[i][j] {
+				continue
+			}
+			link := Link{A: nodes[i].ID, B: nodes[j].ID, State: LinkActive, TerminalA: -1, TerminalB: -1}
+			if needsTerminals(nodes[i], nodes[j]) {
+				if tracked, ok := l.tracked[pairKey(nodes[i].ID, nodes[j].ID)]; ok {
+					link = *tracked
+				} else {
+					link.State = LinkUnassigned
+				}
+			}
+			link.Distance = distance(nodes[i], nodes[j])
+			link.DistanceKm = link.Distance * l.Units.KmPerUnit
+			a, b := nodes[i], nodes[j]
+			if link.A != a.ID {
+				a, b = b, a
+			}
+			link.PortsA = a.LinkPorts(b)
+			link.PortsB = b.LinkPorts(a)
+			l.links = append(l.links, link)
+		}
+	}
+
+	if l.Spectrum != nil {
+		l.assignSpectrum(nodes, index)
+	}
+
+	l.matrix = make([][]bool, len(nodes))
+	for i := range l.matrix {
+		l.matrix[i] = make([]bool, len(nodes))
+	}
+	active := make(map[[2]string]bool)
+	for _, link := range l.links {
+		if link.State == LinkActive {
+			i, j := index[link.A], index[link.B]
+			l.matrix[i][j] = true
+			l.matrix[j][i] = true
+			active[pairKey(link.A, link.B)] = true
+		}
+	}
+
+	l.added, l.removed = diffLinks(l.active, active), diffLinks(active, l.active)
+	l.active = active
+}
+
+// StatelessLinks derives the links from a visibility matrix alone, as a link
+// layer without terminals, spectrum or hysteresis does.
+func StatelessLinks(nodes []*model.Node, visible [][]bool, units Units) []Link {
+	l := NewLinkLayer()
+	l.Units = units
+	l.UpdateVisible(nodes, visible)
+	return l.Links()
+}
+
+// diffLinks returns the keys of next missing from prev, sorted.
+func diffLinks(prev, next map[[2]string]bool) []events.LinkRef {
+	refs := []events.LinkRef{}
+	for key := range next {
+		if !prev[key] {
+			refs = append(refs, events.LinkRef{A: key[0], B: key[1]})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].A != refs[j].A {
+			return refs[i].A < refs[j].A
+		}
+		return refs[i].B < refs[j].B
+	})
+	return refs
+}
+
+// needsTerminals reports whether a link between a and b is an inter-satellite
+// link with at least one terminal-limited end. Ground links are not limited.
+func needsTerminals(a, b *model.Node) bool {
+	if a.IsServer() || b.IsServer() {
+		return false
+	}
+	return a.HasTerminals() || b.HasTerminals()
+}
+
+// freeTerminal picks the idle terminal of n that can point at target with the
+// least slewing. It returns -1 when n has no terminals and -2 when none is free.
+func freeTerminal(n, target *model.Node) int {
+	if !n.HasTerminals() {
+		return -1
+	}
+	angle := n.BodyAngleTo(target)
+	best, bestSlew := -2, math.Inf(1)
+	for i, t := range n.Terminals {
+		if t.Partner != "" || !t.CanPoint(angle, n.TerminalSpec.FieldOfRegard) {
+			continue
+		}
+		if s := math.Abs(model.AngleDiff(angle, t.Pointing)); s < bestSlew {
+			best, bestSlew = i, s
+		}
+	}
+	return best
+}
+
+func inRegard(n *model.Node, terminal int, target *model.Node) bool {
+	if n == nil || terminal < 0 {
+		return true
+	}
+	return n.Terminals[terminal].CanPoint(n.BodyAngleTo(target), n.TerminalSpec.FieldOfRegard)
+}
+
+func slew(n *model.Node, terminal int, target *model.Node) bool {
+	if terminal < 0 {
+		return true
+	}
+	return n.Terminals[terminal].SlewTowards(n.BodyAngleTo(target), n.TerminalSpec.SlewRate)
+}
+
+func acquisitionSteps(n *model.Node, terminal int) int {
+	if terminal < 0 {
+		return 0
+	}
+	return n.TerminalSpec.AcquisitionSteps
+}
+
+func claim(n *model.Node, terminal int, partner string) {
+	if terminal >= 0 {
+		n.Terminals[terminal].Partner = partner
+	}
+}
+
+func release(n *model.Node, terminal int) {
+	if n != nil && terminal >= 0 {
+		n.Terminals[terminal].Partner = ""
+	}
+}
+
+func pairKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+func distance(a, b *model.Node) float64 {
+	x1, y1 := a.Position()
+	x2, y2 := b.Position()
+	return math.Hypot(x2-x1, y2-y1)
+}
@@ -0,0 +1,265 @@
+package simulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"satellite-coms/simulator/model"
+)
+
+// Scenario describes a constellation that can be generated reproducibly from a seed.
+//
+// In the default abstract mode orbits are given as radii in planet radii and
+// speeds in radians per step. In physical mode the planet is given in km and
+// kg (or μ), shells by altitude in km, and angular velocities follow from
+// Kepler's third law for a step of StepSeconds.
+type Scenario struct {
+	Units       string  `json:"units,omitempty"`
+	StepSeconds float64 `json:"step_seconds,omitempty"`
+
+	Planet  PlanetSpec   `json:"planet"`
+	Shells  []ShellSpec  `json:"shells"`
+	Servers []ServerSpec `json:"servers"`
+
+	// Spectrum enables the co-channel interference model; nil disables it
+	Spectrum *SpectrumConfig `json:"spectrum,omitempty"`
+
+	// Hysteresis damps link flapping at the occlusion boundary; nil disables it
+	Hysteresis *HysteresisConfig `json:"hysteresis,omitempty"`
+}
+
+type PlanetSpec struct {
+	Name       string  `json:"name"`
+	Radius     float64 `json:"radius"`
+	ThetaSpeed float64 `json:"theta_speed"`
+
+	// Physical mode only
+	RadiusKm        float64 `json:"radius_km,omitempty"`
+	MassKg          float64 `json:"mass_kg,omitempty"`
+	MuM3PerS2       float64 `json:"mu_m3_s2,omitempty"`
+	RotationPeriodS float64 `json:"rotation_period_s,omitempty"`
+}
+
+// ShellSpec is a ring of evenly spaced satellites sharing one orbit.
+type ShellSpec struct {
+	Name        string  `json:"name"`
+	Satellites  int     `json:"satellites"`
+	OrbitRadius float64 `json:"orbit_radius"`
+	ThetaSpeed  float64 `json:"theta_speed"`
+	AltitudeKm  float64 `json:"altitude_km,omitempty"`
+	Ports       int     `json:"ports"`
+	PortGen     int     `json:"portgen"`
+
+	// Terminals limits the shell's inter-satellite links; nil keeps links unlimited
+	Terminals *model.TerminalSpec `json:"terminals,omitempty"`
+
+	// Antennas gives the field of view of each port, in port order; empty
+	// keeps every port omnidirectional
+	Antennas []model.AntennaSpec `json:"antennas,omitempty"`
+
+	// Channels lists the frequency channels available on each port, in port order
+	Channels [][]int `json:"channels,omitempty"`
+}
+
+type ServerSpec struct {
+	Name     string  `json:"name"`
+	Theta    float64 `json:"theta"`
+	Ports    int     `json:"ports"`
+	PortGen  int     `json:"portgen"`
+	Channels [][]int `json:"channels,omitempty"`
+}
+
+// DefaultScenario mirrors the constellation built by DefaultNodes.
+func DefaultScenario() Scenario {
+	return Scenario{
+		Planet: PlanetSpec{Name: "Earth", Radius: 1, ThetaSpeed: math.Pi / 20480},
+		Shells: []ShellSpec{
+			{Name: "low", Satellites: 3, OrbitRadius: math.Sqrt(2), ThetaSpeed: math.Pi / 5120, Ports: 1, PortGen: 6},
+			{Name: "high", Satellites: 3, OrbitRadius: 3, ThetaSpeed: math.Pi / 10240, Ports: 3, PortGen: 3},
+		},
+		Servers: []ServerSpec{
+			{Name: "Home", Theta: 0, Ports: 2, PortGen: 2},
+			{Name: "Office", Theta: math.Pi, Ports: 6, PortGen: 7},
+		},
+	}
+}
+
+// LoadScenario reads a scenario from a JSON file.
+func LoadScenario(path string) (Scenario, error) {
+	var s Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Physical reports whether the scenario is given in physical units.
+func (s Scenario) Physical() bool {
+	return s.Units == UnitsPhysical
+}
+
+// Mu returns the planet's gravitational parameter in m³/s².
+func (s Scenario) Mu() float64 {
+	if s.Planet.MuM3PerS2 > 0 {
+		return s.Planet.MuM3PerS2
+	}
+	return GravitationalConstant * s.Planet.MassKg
+}
+
+// Scale returns the physical units of the scenario's model.
+func (s Scenario) Scale(p *model.Planet) Units {
+	if !s.Physical() {
+		return EarthUnits(p)
+	}
+	return Units{Mode: UnitsPhysical, KmPerUnit: s.Planet.RadiusKm, SecondsPerStep: s.StepSeconds, MuM3PerS2: s.Mu()}
+}
+
+// Validate checks the units, terminal, antenna, spectrum and hysteresis configuration.
+func (s Scenario) Validate() error {
+	switch s.Units {
+	case "", UnitsAbstract:
+	case UnitsPhysical:
+		if s.StepSeconds <= 0 || s.Planet.RadiusKm <= 0 || s.Mu() <= 0 {
+			return fmt.Errorf("physical units need step_seconds, planet radius_km and mass_kg or mu_m3_s2")
+		}
+		for _, shell := range s.Shells {
+			if shell.AltitudeKm <= 0 {
+				return fmt.Errorf("shell %s: physical units need a positive altitude_km", shell.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown units %q (expected %s or %s)", s.Units, UnitsAbstract, UnitsPhysical)
+	}
+	if sp := s.Spectrum; sp != nil {
+		if sp.ReferenceDistance <= 0 {
+			return fmt.Errorf("spectrum: reference_distance must be positive")
+		}
+		if sp.Mode != SpectrumDrop && sp.Mode != SpectrumDowngrade {
+			return fmt.Errorf("spectrum: unknown mode %q (expected %s or %s)", sp.Mode, SpectrumDrop, SpectrumDowngrade)
+		}
+	}
+	if h := s.Hysteresis; h != nil {
+		if h.DownMargin > h.UpMargin {
+			return fmt.Errorf("hysteresis: down_margin must not exceed up_margin")
+		}
+		if h.MinContactSteps < 0 {
+			return fmt.Errorf("hysteresis: min_contact_steps must not be negative")
+		}
+	}
+	for _, srv := range s.Servers {
+		if err := validateChannels(srv.Name, srv.Ports, srv.Channels); err != nil {
+			return err
+		}
+	}
+	for _, shell := range s.Shells {
+		if err := validateChannels(shell.Name, shell.Ports, shell.Channels); err != nil {
+			return err
+		}
+		if t := shell.Terminals; t != nil && (t.Count < 1 || t.SlewRate <= 0 || t.FieldOfRegard <= 0) {
+			return fmt.Errorf("shell %s: terminals need a positive count, slew_rate and field_of_regard", shell.Name)
+		}
+		if len(shell.Antennas) == 0 {
+			continue
+		}
+		if len(shell.Antennas) != shell.Ports {
+			return fmt.Errorf("shell %s: %d antennas for %d ports", shell.Name, len(shell.Antennas), shell.Ports)
+		}
+		for i, a := range shell.Antennas {
+			if a.Kind != model.AntennaGround && a.Kind != model.AntennaISL {
+				return fmt.Errorf("shell %s: port %d has unknown antenna kind %q", shell.Name, i+1, a.Kind)
+			}
+			if a.MinOffNadir < 0 || a.MaxOffNadir < a.MinOffNadir {
+				return fmt.Errorf("shell %s: port %d has an empty field of view", shell.Name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// Shardable returns an error when the scenario uses link features that keep
+// per-pair state across steps, which a sharded simulation does not maintain.
+func (s Scenario) Shardable() error {
+	if s.Spectrum != nil {
+		return fmt.Errorf("spectrum is not supported in a sharded simulation")
+	}
+	if s.Hysteresis != nil {
+		return fmt.Errorf("hysteresis is not supported in a sharded simulation")
+	}
+	for _, shell := range s.Shells {
+		if shell.Terminals != nil {
+			return fmt.Errorf("shell %s: terminals are not supported in a sharded simulation", shell.Name)
+		}
+	}
+	return nil
+}
+
+// Build generates the scenario nodes. The seed picks a random phase for every
+// shell and, when failureRate > 0, which satellites fail and are left out.
+func (s Scenario) Build(seed int64, failureRate float64) (*model.Planet, []*model.Node) {
+	rng := rand.New(rand.NewSource(seed))
+	p := model.NewPlanet(s.Planet.Name, s.Planet.Radius, s.Planet.ThetaSpeed)
+	if s.Physical() {
+		// The model keeps measuring distances in planet radii
+		p = model.NewPlanet(s.Planet.Name, 1, 0)
+		if s.Planet.RotationPeriodS > 0 {
+			p.ThetaSpeed = 2 * math.Pi / s.Planet.RotationPeriodS * s.StepSeconds
+		}
+	}
+
+	var nodes []*model.Node
+	for _, shell := range s.Shells {
+		if s.Physical() {
+			radiusKm := s.Planet.RadiusKm + shell.AltitudeKm
+			shell.OrbitRadius = radiusKm / s.Planet.RadiusKm
+			shell.ThetaSpeed = KeplerThetaSpeed(s.Mu(), radiusKm, s.StepSeconds)
+		}
+		phase := rng.Float64() * 2 * math.Pi
+		for i := 0; i < shell.Satellites; i++ {
+			if failureRate > 0 && rng.Float64() < failureRate {
+				continue
+			}
+			theta := phase + float64(i)*2*math.Pi/float64(shell.Satellites)
+			name := fmt.Sprintf("%s-%d", shell.Name, i+1)
+			sat := model.NewSatellite(name, p, shell.OrbitRadius, theta, shell.ThetaSpeed, shell.Ports, shell.PortGen)
+			if shell.Terminals != nil {
+				sat.SetTerminals(*shell.Terminals)
+			}
+			sat.Antennas = shell.Antennas
+			sat.PortChannels = shell.Channels
+			nodes = append(nodes, sat)
+		}
+	}
+	for _, srv := range s.Servers {
+		server := model.NewServer(srv.Name, p, srv.Theta, srv.Ports, srv.PortGen)
+		server.PortChannels = srv.Channels
+		nodes = append(nodes, server)
+	}
+	return p, nodes
+}
+
+func validateChannels(name string, ports int, channels [][]int) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	if len(channels) != ports {
+		return fmt.Errorf("%s: channels given for %d of %d ports", name, len(channels), ports)
+	}
+	for i, chs := range channels {
+		for _, ch := range chs {
+			if ch <= 0 {
+				return fmt.Errorf("%s: port %d has invalid channel %d", name, i+1, ch)
+			}
+		}
+	}
+	return nil
+}
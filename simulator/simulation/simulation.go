@@ -8,22 +8,94 @@ import (
 )
 
 var (
-	planet *model.Planet
-	Nodes  []*model.Node
-	Mutex  sync.Mutex
+	planet    *model.Planet
+	Nodes     []*model.Node
+	Links     *LinkLayer
+	StepCount int
+	Mutex     sync.Mutex
 )
 
 func InitSimulation() {
-	planet = model.NewPlanet("Earth", 1, math.Pi/20480)
-
-	Nodes = []*model.Node{
-		model.NewSatellite("Gonzalito", planet, math.Sqrt(2), 3*(math.Pi*2/3), math.Pi/5120, 1, 6),
-		model.NewSatellite("Giovanni", planet, math.Sqrt(2), 2*(math.Pi*2/3), math.Pi/5120, 1, 6),
-		model.NewSatellite("Martina", planet, math.Sqrt(2), 1*(math.Pi*2/3), math.Pi/5120, 1, 6),
-
-		model.NewSatellite("Bonnie", planet, 3, 3*(math.Pi*2/3), math.Pi/10240, 3, 3),
-		model.NewSatellite("Kissie", planet, 3, 2*(math.Pi*2/3), math.Pi/10240, 3, 3),
-		model.NewSatellite("Honey", planet, 3, 1*(math.Pi*2/3), math.Pi/10240, 3, 3),
-		model.NewServer("Home", planet, 0, 2, 2),
-		model.NewServer("Office", planet, math.Pi, 6, 7)}
+	planet, Nodes = DefaultNodes()
+	reset(EarthUnits(planet), nil, nil)
+}
+
+// InitScenario replaces the default constellation with a generated scenario.
+func InitScenario(s Scenario, seed int64) {
+	planet, Nodes = s.Build(seed, 0)
+	reset(s.Scale(planet), s.Spectrum, s.Hysteresis)
+}
+
+func reset(units Units, spectrum *SpectrumConfig, hysteresis *HysteresisConfig) {
+	StepCount = 0
+	Scale = units
+	Links = NewLinkLayer()
+	Links.Units = units
+	Links.Spectrum = spectrum
+	Links.Hysteresis = hysteresis
+	Links.Update(Nodes)
+}
+
+// Planet returns the planet the service nodes orbit.
+func Planet() *model.Planet {
+	return planet
+}
+
+// DefaultNodes builds the default constellation around a fresh planet, so callers
+// can run the model without touching the service globals.
+func DefaultNodes() (*model.Planet, []*model.Node) {
+	p := model.NewPlanet("Earth", 1, math.Pi/20480)
+
+	return p, []*model.Node{
+		model.NewSatellite("Gonzalito", p, math.Sqrt(2), 3*(math.Pi*2/3), math.Pi/5120, 1, 6),
+		model.NewSatellite("Giovanni", p, math.Sqrt(2), 2*(math.Pi*2/3), math.Pi/5120, 1, 6),
+		model.NewSatellite("Martina", p, math.Sqrt(2), 1*(math.Pi*2/3), math.Pi/5120, 1, 6),
+
+		model.NewSatellite("Bonnie", p, 3, 3*(math.Pi*2/3), math.Pi/10240, 3, 3),
+		model.NewSatellite("Kissie", p, 3, 2*(math.Pi*2/3), math.Pi/10240, 3, 3),
+		model.NewSatellite("Honey", p, 3, 1*(math.Pi*2/3), math.Pi/10240, 3, 3),
+		model.NewServer("Home", p, 0, 2, 2),
+		model.NewServer("Office", p, math.Pi, 6, 7)}
+}
+
+// Step moves every node one simulation tick forward.
+func Step(nodes []*model.Node) {
+	for _, node := range nodes {
+		node.Move()
+	}
+}
+
+// AdvanceTo moves every node to the given step, forward or backward, without
+// updating the links. Sharded instances follow the designated shard with it.
+// The caller must hold Mutex.
+func AdvanceTo(step int) {
+	for _, node := range Nodes {
+		node.OrbitTheta += float64(step-StepCount) * node.ThetaSpeed
+	}
+	StepCount = step
+}
+
+// PropagateNodes returns copies of the nodes moved the given number of steps,
+// leaving the originals untouched.
+func PropagateNodes(nodes []*model.Node, steps int) []*model.Node {
+	copies := make([]*model.Node, len(nodes))
+	for i, node := range nodes {
+		copies[i] = node.Propagate(steps)
+	}
+	return copies
+}
+
+// VisibilityMatrix returns which nodes can link with each other: they must be
+// in line of sight and inside each other's antenna fields of view.
+func VisibilityMatrix(nodes []*model.Node) [][]bool {
+	matrix := make([][]bool, len(nodes))
+	for i := range matrix {
+		matrix[i] = make([]bool, len(nodes))
+		for j := range matrix[i] {
+			if i != j {
+				matrix[i][j] = nodes[i].CanLink(nodes[j])
+			}
+		}
+	}
+	return matrix
 }
@@ -0,0 +1,214 @@
+package simulation
+
+import (
+	"math"
+	"sort"
+
+	"satellite-coms/simulator/model"
+)
+
+// Modes applied to links whose SINR falls below the threshold.
+const (
+	SpectrumDrop      = "drop"
+	SpectrumDowngrade = "downgrade"
+)
+
+// Link states of the spectrum model: dropped for a low SINR, or dropped
+// because no free channel is shared by both ends.
+const (
+	LinkInterfered = "interfered"
+	LinkNoChannel  = "no_channel"
+)
+
+// SpectrumConfig is a free-space interference model. Every transmitter has the
+// same power, so a signal received over distance d has an SNR of
+// ReferenceSNRdB - 20*log10(d/ReferenceDistance). Distances are in planet radii.
+type SpectrumConfig struct {
+	ReferenceSNRdB    float64 `json:"reference_snr_db"`
+	ReferenceDistance float64 `json:"reference_distance"`
+	ThresholdDB       float64 `json:"threshold_db"`
+	Mode              string  `json:"mode"`
+}
+
+// channelAssignment is the channel a link uses and the port carrying it at
+// each end, as 1-based port numbers.
+type channelAssignment struct {
+	channel      int
+	portA, portB int
+}
+
+// portChannel is one channel of one port; it carries at most one link.
+type portChannel struct {
+	node          string
+	port, channel int
+}
+
+// assignSpectrum gives every usable link a channel shared by both ends, then
+// computes its SINR against all other transmitters on the same channel. Links
+// whose ends have no channels configured are left out of the spectrum model.
+//
+// Every channel of a port carries at most one link. Links keep their channel
+// and ports from the previous step while they stay free; the others are
+// assigned shortest first, and a link left without a free common channel is
+// dropped.
+func (l *LinkLayer) assignSpectrum(nodes []*model.Node, index map[string]int) {
+	type transmitter struct {
+		node    *model.Node
+		channel int
+	}
+
+	var candidates []int
+	for i, link := range l.links {
+		if link.State != LinkActive {
+			continue
+		}
+		if len(nodes[index[link.A]].PortChannels) == 0 || len(nodes[index[link.B]].PortChannels) == 0 {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(x, y int) bool {
+		_, keepX := l.assigned[pairKey(l.links[candidates[x]].A, l.links[candidates[x]].B)]
+		_, keepY := l.assigned[pairKey(l.links[candidates[y]].A, l.links[candidates[y]].B)]
+		if keepX != keepY {
+			return keepX
+		}
+		return l.links[candidates[x]].Distance < l.links[candidates[y]].Distance
+	})
+
+	// Greedy assignment: pick the free common channel with the fewest
+	// co-channel transmitters already in view of either end
+	// A node transmitting on one channel over several links counts once
+	var transmitters []transmitter
+	seen := make(map[transmitter]bool)
+	transmit := func(t transmitter) {
+		if !seen[t] {
+			seen[t] = true
+			transmitters = append(transmitters, t)
+		}
+	}
+	used := make(map[portChannel]bool)
+	assigned := make(map[[2]string]channelAssignment)
+	for _, i := range candidates {
+		link := &l.links[i]
+		a, b := nodes[index[link.A]], nodes[index[link.B]]
+		key := pairKey(link.A, link.B)
+
+		options := freeChannels(a, link.PortsA, b, link.PortsB, used)
+		if len(options) == 0 {
+			link.State = LinkNoChannel
+			continue
+		}
+		best, bestLoad := options[0], math.MaxInt
+		for _, option := range options {
+			if prev, ok := l.assigned[key]; ok && option == prev {
+				best = option
+				break
+			}
+			load := 0
+			for _, t := range transmitters {
+				if t.channel == option.channel && t.node != a && t.node != b && (t.node.CanView(a) || t.node.CanView(b)) {
+					load++
+				}
+			}
+			if load < bestLoad {
+				best, bestLoad = option, load
+			}
+		}
+		link.Channel = best.channel
+		used[portChannel{a.ID, best.portA, best.channel}] = true
+		used[portChannel{b.ID, best.portB, best.channel}] = true
+		assigned[key] = best
+		transmit(transmitter{a, best.channel})
+		transmit(transmitter{b, best.channel})
+	}
+	l.assigned = assigned
+
+	noise := 1.0
+	reference := math.Pow(10, l.Spectrum.ReferenceSNRdB/10) * noise
+	received := func(from, to *model.Node) float64 {
+		d := math.Max(distance(from, to), 1e-9)
+		return reference * math.Pow(l.Spectrum.ReferenceDistance/d, 2)
+	}
+
+	for _, i := range candidates {
+		link := &l.links[i]
+		if link.Channel == 0 {
+			continue
+		}
+		a, b := nodes[index[link.A]], nodes[index[link.B]]
+
+		// The link is as good as its worse direction
+		sinr := math.Inf(1)
+		for _, dir := range [][2]*model.Node{{a, b}, {b, a}} {
+			tx, rx := dir[0], dir[1]
+			interference := 0.0
+			for _, t := range transmitters {
+				if t.channel == link.Channel && t.node != tx && t.node != rx && t.node.CanView(rx) {
+					interference += received(t.node, rx)
+				}
+			}
+			sinr = math.Min(sinr, received(tx, rx)/(noise+interference))
+		}
+		sinrDB := 10 * math.Log10(sinr)
+		link.SINRdB = &sinrDB
+
+		if sinrDB < l.Spectrum.ThresholdDB {
+			if l.Spectrum.Mode == SpectrumDowngrade {
+				link.Degraded = true
+			} else {
+				link.State = LinkInterfered
+			}
+		}
+	}
+}
+
+// freeChannels returns every channel available on both ends through ports
+// whose channel is still free, restricted to the given ports (nil meaning every
+// port), in ascending order of channel, then ports.
+func freeChannels(a *model.Node, portsA []int, b *model.Node, portsB []int, used map[portChannel]bool) []channelAssignment {
+	var options []channelAssignment
+	for _, ca := range portChannels(a, portsA) {
+		if used[portChannel{a.ID, ca.port, ca.channel}] {
+			continue
+		}
+		for _, cb := range portChannels(b, portsB) {
+			if cb.channel == ca.channel && !used[portChannel{b.ID, cb.port, cb.channel}] {
+				options = append(options, channelAssignment{channel: ca.channel, portA: ca.port, portB: cb.port})
+			}
+		}
+	}
+	sort.Slice(options, func(x, y int) bool {
+		if options[x].channel != options[y].channel {
+			return options[x].channel < options[y].channel
+		}
+		if options[x].portA != options[y].portA {
+			return options[x].portA < options[y].portA
+		}
+		return options[x].portB < options[y].portB
+	})
+	return options
+}
+
+// portChannels lists the channels of the given ports (nil meaning every port).
+func portChannels(n *model.Node, ports []int) []portChannel {
+	var channels []portChannel
+	for p, chs := range n.PortChannels {
+		if ports != nil && !containsInt(ports, p+1) {
+			continue
+		}
+		for _, ch := range chs {
+			channels = append(channels, portChannel{n.ID, p + 1, ch})
+		}
+	}
+	return channels
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
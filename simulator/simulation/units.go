@@ -0,0 +1,70 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+
+	"satellite-coms/simulator/model"
+)
+
+const (
+	earthRadiusKm        = 6371.0
+	siderealDaySeconds   = 86164.0905
+	SpeedOfLightKmPerSec = 299792.458
+	DefaultCarrierHz     = 2.2e9
+
+	// GravitationalConstant is G in m³/(kg·s²)
+	GravitationalConstant = 6.67430e-11
+)
+
+// Unit modes of a scenario.
+const (
+	UnitsAbstract = "abstract"
+	UnitsPhysical = "physical"
+)
+
+// Units converts model units into physical ones. The model always measures
+// distances in planet radii and time in steps; in abstract mode the physical
+// scale is inferred from Earth, in physical mode it comes from the scenario.
+type Units struct {
+	Mode           string  `json:"mode"`
+	KmPerUnit      float64 `json:"km_per_unit"`
+	SecondsPerStep float64 `json:"seconds_per_step"`
+	MuM3PerS2      float64 `json:"mu_m3_s2,omitempty"`
+}
+
+// Scale holds the physical units of the service simulation.
+var Scale Units
+
+// EarthUnits maps the planet radius to Earth's radius and derives the step
+// duration from the planet's rotation per step, assuming a sidereal day.
+func EarthUnits(p *model.Planet) Units {
+	u := Units{Mode: UnitsAbstract, KmPerUnit: earthRadiusKm / p.Radius, SecondsPerStep: 1}
+	if p.ThetaSpeed != 0 {
+		u.SecondsPerStep = siderealDaySeconds * math.Abs(p.ThetaSpeed) / (2 * math.Pi)
+	}
+	return u
+}
+
+// SimTime returns the simulated time, in seconds, at the given step.
+func (u Units) SimTime(step int) float64 {
+	return float64(step) * u.SecondsPerStep
+}
+
+// StepAt returns the step closest to the given sim time in seconds. Times
+// that are not finite or lie beyond the int32 range of steps are rejected.
+func (u Units) StepAt(t float64) (int, error) {
+	step := math.Round(t / u.SecondsPerStep)
+	if math.IsNaN(step) || step < math.MinInt32 || step > math.MaxInt32 {
+		return 0, fmt.Errorf("sim time %g s is out of range", t)
+	}
+	return int(step), nil
+}
+
+// KeplerThetaSpeed returns the angular velocity, in radians per step, of a
+// circular orbit of the given radius in km around a body with gravitational
+// parameter mu (m³/s²), from Kepler's third law ω = √(μ/a³).
+func KeplerThetaSpeed(mu, radiusKm, secondsPerStep float64) float64 {
+	a := radiusKm * 1000
+	return math.Sqrt(mu/(a*a*a)) * secondsPerStep
+}